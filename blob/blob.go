@@ -3,82 +3,157 @@ package blob
 import (
 	"errors"
 	"fmt"
-	"image/color"
 	"math"
 	"math/rand"
+	"sort"
 
 	"private/grow/render"
 
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
 )
 
 type BlobConfig struct {
-	Nodes     map[NodeType]*NodeConfig         `json:"nodes"`
-	Jobs      map[JobType]*JobConfig           `json:"jobs"`
-	Resources map[ResourceType]*ResourceConfig `json:"resources"`
-	Unit      *UnitConfig                      `json:"unit"`
+	Nodes             map[NodeType]*NodeConfig             `json:"nodes"`
+	Connections       map[ConnectionType]*ConnectionConfig `json:"connections"`
+	DefaultConnection ConnectionType                       `json:"default_connection"`
+	Jobs              map[JobType]*JobConfig               `json:"jobs"`
+	Recipes           map[RecipeType]*RecipeConfig         `json:"recipes"`
+	Resources         map[ResourceType]*ResourceConfig     `json:"resources"`
+	Unit              *UnitConfig                          `json:"unit"`
+	Pathfinding       *PathfindingConfig                   `json:"pathfinding"`
+	Starter           *StarterConfig                       `json:"starter"`
+}
+
+// StarterConfig is the layout a new game starts with.
+type StarterConfig struct {
+	Nodes       []*StarterNodeConfig `json:"nodes"`
+	Connections [][2]int             `json:"connections"` // indices of Nodes
+}
+
+type StarterNodeConfig struct {
+	NodeType NodeType  `json:"node_type"`
+	Pos      pixel.Vec `json:"pos"`
+	Units    int       `json:"units"` // number of units spawned on the node
 }
 
 type Blob struct {
+	tick                int
 	nodesIdentifier     int
 	resourcesIdentifier int
 	jobsIdentifier      int
 	unitsIdentifier     int
+	pilesIdentifier     int
 	Nodes               map[int]*Node
 	Connections         []*Connection // TODO: make this a map[ConnectionIDs]*connection
 	Units               map[int]*Unit
+	piles               map[int]*Pile
 	jobs                *JobQueue
 	consumers           map[ResourceType]map[int][]int // mapping from resource type to map of priorities to node IDs
 	producers           map[ResourceType]map[int][]int // mapping from resource type to node IDs
-	pathCache           map[string][]int               // TODO: make this a map[ConnectionIDs][]int
+	adjacency           map[int][]*Connection
+	pathCache           map[pathKey]cachedPath // by length
+	tickPaths           map[pathKey]cachedPath // by other costs, this tick
+	edgeCost            EdgeCost
+	traffic             map[ConnectionIDs]int // units traversing connections
 	connected           map[ConnectionIDs]bool
+	seed                int64
+	randSource          *randSource
+	rand                *rand.Rand
+
+	subscribers           map[int]EventHandler
+	subscribersIdentifier int
 
-	rend *render.Renderer
 	conf *BlobConfig
 }
 
 type BlobJSON struct {
+	Tick                int                            `json:"tick"`
 	NodesIdentifier     int                            `json:"nodes_identifier"`
 	ResourcesIdentifier int                            `json:"resources_identifier"`
 	JobsIdentifier      int                            `json:"jobs_identifier"`
 	UnitsIdentifier     int                            `json:"units_identifier"`
+	PilesIdentifier     int                            `json:"piles_identifier"`
 	Nodes               map[int]*NodeJSON              `json:"nodes"`
 	Connections         []*Connection                  `json:"connections"`
 	Units               map[int]*UnitJSON              `json:"units"`
+	Piles               map[int]*PileJSON              `json:"piles"`
 	Jobs                *JobQueueJSON                  `json:"jobs"`
 	Consumers           map[ResourceType]map[int][]int `json:"consumers"`
 	Producers           map[ResourceType]map[int][]int `json:"producers"`
+	Seed                int64                          `json:"seed"`
+	RandState           uint64                         `json:"rand_state"`
 
 	// TODO: SAVE PATH CACHE
 	// PathCache           map[ConnectionIDs][]int `json:"path_cache"`
 }
 
-func NewBlob(bj *BlobJSON, conf *BlobConfig, win *pixelgl.Window) *Blob {
+// NewBlob creates a blob from its saved state. The blob holds only simulation
+// state, it can be updated and saved without a window, rendering is done by
+// passing a renderer to Render.
+func NewBlob(bj *BlobJSON, conf *BlobConfig) *Blob {
 	b := &Blob{
+		tick:                bj.Tick,
 		nodesIdentifier:     bj.NodesIdentifier,
 		resourcesIdentifier: bj.ResourcesIdentifier,
 		jobsIdentifier:      bj.JobsIdentifier,
 		unitsIdentifier:     bj.UnitsIdentifier,
+		pilesIdentifier:     bj.PilesIdentifier,
 		Nodes:               make(map[int]*Node),
 		Connections:         bj.Connections,
 		Units:               make(map[int]*Unit),
-		pathCache:           make(map[string][]int),
+		piles:               make(map[int]*Pile),
+		adjacency:           make(map[int][]*Connection),
+		pathCache:           make(map[pathKey]cachedPath),
+		tickPaths:           make(map[pathKey]cachedPath),
+		edgeCost:            LengthCost{},
+		traffic:             make(map[ConnectionIDs]int),
 		connected:           make(map[ConnectionIDs]bool),
-		rend:                render.NewRenderer(win),
+		seed:                bj.Seed,
+		randSource:          newRandSource(bj.Seed, bj.RandState),
+		subscribers:         make(map[int]EventHandler),
 		conf:                conf,
 	}
 
+	b.rand = rand.New(b.randSource)
+
+	if conf.Pathfinding != nil {
+		b.edgeCost = &TrafficCost{
+			Blob:   b,
+			Weight: conf.Pathfinding.TrafficWeight,
+		}
+	}
+
 	for id, node := range bj.Nodes {
 		b.Nodes[id] = NewNode(node, b, conf)
 	}
 
+	for _, conn := range bj.Connections {
+		if conn.Type == "" {
+			conn.Type = conf.DefaultConnection
+		}
+
+		b.indexConnection(conn)
+	}
+
 	for _, unit := range bj.Units {
 		b.Units[unit.ID] = NewUnit(unit, b)
 	}
 
-	for _, conn := range bj.Connections {
-		b.connected[conn.Nodes] = true
+	for id, pile := range bj.Piles {
+		b.piles[id] = NewPile(pile)
+	}
+
+	for _, unit := range b.Units {
+		if unit.traversingConnection != nil {
+			// share the graph's connection, its length changes with nodes
+			// being moved
+			connIDs := unit.traversingConnection.Nodes
+
+			unit.traversingConnection = b.GetConnection(connIDs)
+			b.traffic[connIDs]++
+		}
+
+		unit.prevPos = unit.Pos()
 	}
 
 	b.consumers = bj.Consumers
@@ -96,13 +171,66 @@ func NewBlob(bj *BlobJSON, conf *BlobConfig, win *pixelgl.Window) *Blob {
 	return b
 }
 
+// NewBlobJSON returns the save of a new game, built from the starter layout in
+// config.
+func NewBlobJSON(conf *BlobConfig, seed int64) (*BlobJSON, error) {
+	b := NewBlob(&BlobJSON{Seed: seed}, conf)
+
+	if conf.Starter == nil {
+		return b.ToJSON(), nil
+	}
+
+	ids := make([]int, 0, len(conf.Starter.Nodes))
+
+	for i, n := range conf.Starter.Nodes {
+		if conf.Nodes[n.NodeType] == nil {
+			return nil, fmt.Errorf(
+				"starter node %d: unknown node type %q",
+				i,
+				n.NodeType,
+			)
+		}
+
+		id := b.AddNode(n.Pos, n.NodeType)
+		ids = append(ids, id)
+
+		for u := 0; u < n.Units; u++ {
+			b.AddUnit(id)
+		}
+	}
+
+	for i, conn := range conf.Starter.Connections {
+		if conn[0] < 0 || conn[0] >= len(ids) ||
+			conn[1] < 0 || conn[1] >= len(ids) {
+			return nil, fmt.Errorf(
+				"starter connection %d: node index out of range",
+				i,
+			)
+		}
+
+		// the starter layout is free, no build cost is paid
+		connIDs, err := b.connectionIDs(ids[conn[0]], ids[conn[1]])
+		if err != nil {
+			return nil, fmt.Errorf("starter connection %d: %w", i, err)
+		}
+
+		if !b.connected[connIDs] {
+			b.addConnection(connIDs, conf.DefaultConnection)
+		}
+	}
+
+	return b.ToJSON(), nil
+}
+
 func (b *Blob) ToJSON() *BlobJSON {
 	bj := &BlobJSON{}
 
+	bj.Tick = b.tick
 	bj.NodesIdentifier = b.nodesIdentifier
 	bj.ResourcesIdentifier = b.resourcesIdentifier
 	bj.JobsIdentifier = b.jobsIdentifier
 	bj.UnitsIdentifier = b.unitsIdentifier
+	bj.PilesIdentifier = b.pilesIdentifier
 
 	bj.Nodes = make(map[int]*NodeJSON)
 	for id, node := range b.Nodes {
@@ -116,41 +244,77 @@ func (b *Blob) ToJSON() *BlobJSON {
 		bj.Units[id] = unit.ToJSON()
 	}
 
+	bj.Piles = make(map[int]*PileJSON)
+	for id, pile := range b.piles {
+		bj.Piles[id] = pile.ToJSON()
+	}
+
 	bj.Jobs = b.jobs.ToJSON()
 
 	bj.Consumers = b.consumers
 	bj.Producers = b.producers
 
+	bj.Seed = b.seed
+	bj.RandState = b.randSource.state
+
 	return bj
 }
 
-func (b *Blob) Render() {
+// Render draws the blob. alpha, from 0 to 1, is the time passed since the last
+// tick as a fraction of a tick, unit positions are interpolated by it.
+func (b *Blob) Render(rend *render.Renderer, alpha float64) {
 	for _, conn := range b.Connections {
 		n1 := b.Nodes[conn.Nodes.Node1]
 		n2 := b.Nodes[conn.Nodes.Node2]
 
-		b.rend.Line(n1.pos, n2.pos, color.RGBA{255, 255, 255, 255}, 8)
+		conf := b.connectionConfig(conn.Type)
+
+		rend.Line(n1.pos, n2.pos, conf.Color, conf.Thickness)
 	}
 
 	for _, node := range b.Nodes {
-		node.Render(b.rend)
+		node.Render(rend)
+	}
+
+	for _, pile := range b.piles {
+		pile.Render(rend, b.conf)
 	}
 
 	for _, unit := range b.Units {
-		unit.Render(b.rend)
+		unit.Render(rend, alpha)
 	}
 
-	b.rend.Render()
+	rend.Render()
 }
 
+// Update advances the simulation by one tick. Units and nodes are updated in
+// order of their IDs, so that the same save always plays out the same way.
 func (b *Blob) Update() {
-	for _, unit := range b.Units {
+	b.expirePaths()
+
+	for _, id := range SortedKeys(b.Units) {
+		unit, ok := b.Units[id]
+		if !ok { // died during this update
+			continue
+		}
+
+		unit.prevPos = unit.Pos()
+
+		before := *unit.CurrentProcedureStep()
 		unit.Update()
+		unit.traceStep(&before)
 	}
 
-	for _, node := range b.Nodes {
-		node.Update()
+	for _, id := range SortedKeys(b.Nodes) {
+		b.Nodes[id].Update()
 	}
+
+	b.tick++
+}
+
+// Tick returns the number of ticks the simulation has run for.
+func (b *Blob) Tick() int {
+	return b.tick
 }
 
 func (b *Blob) AddNode(pos pixel.Vec, nodeType NodeType) int {
@@ -164,6 +328,46 @@ func (b *Blob) AddNode(pos pixel.Vec, nodeType NodeType) int {
 		b.conf,
 	)
 
+	b.registerNode(node)
+
+	b.jobs.Add(node.Jobs()...)
+
+	b.nodesIdentifier++
+	b.Nodes[node.id] = node
+
+	b.emit(&NodeBuilt{NodeID: node.id, NodeType: nodeType, Pos: pos})
+
+	return node.id
+}
+
+// retype swaps the node's type and its consumer and producer registration.
+func (b *Blob) retype(node *Node, nodeType NodeType, conf *NodeConfig) {
+	removeID(b.consumers, node.id)
+	removeID(b.producers, node.id)
+
+	node.nodeType = nodeType
+	node.conf = conf
+
+	b.registerNode(node)
+}
+
+// removeJob removes a job from the queue, units doing it are reset.
+func (b *Blob) removeJob(job *Job) {
+	b.jobs.Remove(job)
+
+	for _, id := range SortedKeys(b.Units) {
+		u := b.Units[id]
+
+		if u.job != nil && u.job.id == job.id {
+			u.job = nil // removed from the queue
+			u.reset()
+		}
+	}
+}
+
+// registerNode adds the node to consumers and producers of the resources its
+// type consumes and produces.
+func (b *Blob) registerNode(node *Node) {
 	for res, priority := range node.conf.Consumes {
 		priorities := b.consumers[res]
 		if priorities == nil {
@@ -183,13 +387,110 @@ func (b *Blob) AddNode(pos pixel.Vec, nodeType NodeType) int {
 
 		priorities[priority] = append(priorities[priority], node.id)
 	}
+}
 
-	b.jobs.Add(node.Jobs()...)
+// MoveNode moves a node with its stock, piles and the units on it. Lengths of
+// its connections are recalculated, units traversing them keep their relative
+// progress.
+func (b *Blob) MoveNode(id int, pos pixel.Vec) error {
+	node, ok := b.Nodes[id]
+	if !ok {
+		return errors.New("node not found")
+	}
 
-	b.nodesIdentifier++
-	b.Nodes[node.id] = node
+	delta := pos.Sub(node.pos)
+	node.pos = pos
 
-	return node.id
+	for _, positions := range node.resources {
+		for i := range positions {
+			positions[i] = positions[i].Add(delta)
+		}
+	}
+
+	for _, pile := range b.piles {
+		if pile.nodeID == id {
+			pile.pos = pile.pos.Add(delta)
+		}
+	}
+
+	for _, conn := range b.adjacency[id] {
+		length := b.Nodes[conn.Nodes.Node1].pos.
+			Sub(b.Nodes[conn.Nodes.Node2].pos).
+			Len()
+
+		for _, u := range b.Units {
+			if u.traversingConnection != conn {
+				continue
+			}
+
+			if conn.Length > 0 {
+				u.traversingProgress *= length / conn.Length
+			} else {
+				u.traversingProgress = 0
+			}
+		}
+
+		conn.Length = length
+	}
+
+	for _, u := range b.Units {
+		if u.nodeID == id {
+			u.stationaryPos = u.stationaryPos.Add(delta)
+			u.stationaryTarget = u.stationaryTarget.Add(delta)
+		}
+	}
+
+	b.invalidatePaths()
+
+	return nil
+}
+
+// SetNodeType changes the type of a node in place. Consumer and producer
+// registration follows the new type. Jobs of types the new type still has are
+// kept, others are removed and their units reset. Stock over the new capacity
+// is dropped, starting with resources the node has no use for.
+func (b *Blob) SetNodeType(id int, nodeType NodeType) error {
+	node, ok := b.Nodes[id]
+	if !ok {
+		return errors.New("node not found")
+	}
+
+	conf, ok := b.conf.Nodes[nodeType]
+	if !ok {
+		return fmt.Errorf("unknown node type %q", nodeType)
+	}
+
+	b.retype(node, nodeType, conf)
+
+	keep := make(map[JobType]int)
+	for _, jobType := range conf.Jobs {
+		keep[jobType]++
+	}
+
+	for _, job := range b.jobs.NodeJobs(id) {
+		if keep[job.jobType] > 0 {
+			keep[job.jobType]--
+			continue
+		}
+
+		b.removeJob(job)
+	}
+
+	for _, jobType := range conf.Jobs {
+		if keep[jobType] > 0 {
+			keep[jobType]--
+			b.jobs.Add(node.newJob(jobType))
+		}
+	}
+
+	if !node.hasRecipe(node.recipe) {
+		node.recipe = ""
+		node.productionProgress = 0
+	}
+
+	node.fitResources()
+
+	return nil
 }
 
 func (b *Blob) AddUnit(nodeID int) *Unit {
@@ -197,14 +498,17 @@ func (b *Blob) AddUnit(nodeID int) *Unit {
 	u.id = b.unitsIdentifier
 	b.unitsIdentifier++
 	u.SetCurrentProcedureStep(Wander)
+	u.prevPos = u.Pos()
 
 	b.Units[u.id] = u
 
+	b.emit(&UnitSpawned{UnitID: u.id, NodeID: nodeID})
+
 	return u
 }
 
 func (b *Blob) GetConnection(connIDs ConnectionIDs) *Connection {
-	for _, conn := range b.Connections {
+	for _, conn := range b.adjacency[connIDs.Node1] {
 		if conn.Nodes == connIDs {
 			return conn
 		}
@@ -214,60 +518,289 @@ func (b *Blob) GetConnection(connIDs ConnectionIDs) *Connection {
 }
 
 func (b *Blob) GetNodeConnections(id int) []*Connection {
-	var connections []*Connection
+	return b.adjacency[id]
+}
 
-	for _, conn := range b.Connections {
-		if conn.Nodes.Node1 == id || conn.Nodes.Node2 == id {
-			connections = append(connections, conn)
+// Connect connects two nodes with a connection of the given type, an empty
+// type is the default connection type. The build cost of the type is taken
+// from the stock of nodes. Nodes that are already connected keep their
+// connection.
+func (b *Blob) Connect(
+	id1, id2 int,
+	connType ConnectionType,
+) (*Connection, error) {
+	connIDs, err := b.connectionIDs(id1, id2)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.connected[connIDs] {
+		return b.GetConnection(connIDs), nil
+	}
+
+	if connType == "" {
+		connType = b.conf.DefaultConnection
+	}
+
+	conf, ok := b.conf.Connections[connType]
+	if !ok && connType != b.conf.DefaultConnection {
+		return nil, fmt.Errorf("unknown connection type %q", connType)
+	}
+
+	if ok {
+		err = b.payBuildCost(conf.BuildCost)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return connections
+	return b.addConnection(connIDs, connType), nil
 }
 
-func (b *Blob) Connect(id1, id2 int) (*Connection, error) {
+func (b *Blob) connectionIDs(id1, id2 int) (ConnectionIDs, error) {
 	if id1 == id2 {
-		return nil, errors.New("cannot connect to self")
+		return ConnectionIDs{}, errors.New("cannot connect to self")
+	}
+
+	if b.Nodes[id1] == nil || b.Nodes[id2] == nil {
+		return ConnectionIDs{}, errors.New("node not found")
 	}
 
-	n1 := b.Nodes[id1]
-	n2 := b.Nodes[id2]
+	return NewConnectionIDs(id1, id2), nil
+}
+
+// payBuildCost takes the cost from the stock of nodes in order of their IDs.
+// Nothing is taken unless the whole cost is in stock.
+func (b *Blob) payBuildCost(cost map[ResourceType]int) error {
+	ids := SortedKeys(b.Nodes)
+
+	for _, res := range SortedKeys(cost) {
+		count := 0
+		for _, id := range ids {
+			count += b.Nodes[id].ResourceCount(res)
+		}
 
-	if n1 == nil || n2 == nil {
-		return nil, errors.New("node not found")
+		if count < cost[res] {
+			return fmt.Errorf(
+				"not enough %s: %d of %d in stock",
+				res,
+				count,
+				cost[res],
+			)
+		}
 	}
 
-	connIDs := NewConnectionIDs(id1, id2)
+	for _, res := range SortedKeys(cost) {
+		left := cost[res]
 
-	if b.connected[connIDs] {
-		return b.GetConnection(connIDs), nil
+		for _, id := range ids {
+			for left > 0 && b.Nodes[id].TakeResource(res) == nil {
+				left--
+			}
+		}
 	}
 
-	return b.addConnection(connIDs), nil
+	return nil
 }
 
-func (b *Blob) addConnection(connIDs ConnectionIDs) *Connection {
+func (b *Blob) addConnection(
+	connIDs ConnectionIDs,
+	connType ConnectionType,
+) *Connection {
 	n1 := b.Nodes[connIDs.Node1]
 	n2 := b.Nodes[connIDs.Node2]
 
 	c := &Connection{
 		Nodes:  connIDs,
 		Length: n1.pos.Sub(n2.pos).Len(),
+		Type:   connType,
 	}
 
-	b.connected[connIDs] = true
 	b.Connections = append(b.Connections, c)
+	b.indexConnection(c)
+	b.invalidatePaths()
 
 	return c
 }
 
+// Disconnect removes the connection between two nodes. Units traversing it go
+// back to the node they came from and, like units whose path led over it, find
+// a new path.
+func (b *Blob) Disconnect(id1, id2 int) error {
+	connIDs := NewConnectionIDs(id1, id2)
+
+	if !b.connected[connIDs] {
+		return errors.New("nodes are not connected")
+	}
+
+	b.removeConnection(connIDs)
+	b.rerouteUnits()
+
+	return nil
+}
+
+// RemoveNode removes a node with its connections and jobs. Units and piles on
+// the node are moved to the closest remaining node, units working at or
+// heading to it drop what they were doing. Units on the last node die.
+func (b *Blob) RemoveNode(id int) error {
+	node, ok := b.Nodes[id]
+	if !ok {
+		return errors.New("node not found")
+	}
+
+	if len(b.Nodes) == 1 {
+		// units on the last node have nowhere to go, what they carry is lost
+		// with the node
+		for _, unitID := range SortedKeys(b.Units) {
+			u := b.Units[unitID]
+
+			u.pileResource("node removed")
+			u.Die()
+		}
+	}
+
+	for _, conn := range append([]*Connection(nil), b.adjacency[id]...) {
+		b.removeConnection(conn.Nodes)
+	}
+
+	delete(b.Nodes, id)
+	delete(b.adjacency, id)
+
+	removeID(b.consumers, id)
+	removeID(b.producers, id)
+
+	b.jobs.RemoveNode(id)
+
+	for _, unitID := range SortedKeys(b.Units) {
+		u := b.Units[unitID]
+
+		// the walked part of the path may lead over the node
+		if u.traversingStep > 0 {
+			u.traversingPath = u.traversingPath[u.traversingStep:]
+			u.traversingStep = 0
+		}
+
+		if u.job != nil && u.job.nodeID == id {
+			u.job = nil // removed from the queue with the node
+			u.reset()
+		}
+
+		if u.nodeID == id {
+			// a node is left, units on the last node died above
+			u.nodeID, _ = b.GetClosestNode(node.pos)
+			u.reset()
+		}
+
+		if u.heading(id) {
+			u.reset()
+		}
+	}
+
+	b.movePiles(node)
+	b.rerouteUnits()
+
+	return nil
+}
+
+// removeConnection removes the connection from the graph, units traversing it
+// are put back on the node they came from.
+func (b *Blob) removeConnection(connIDs ConnectionIDs) {
+	for _, id := range SortedKeys(b.Units) {
+		u := b.Units[id]
+
+		if u.traversingConnection != nil &&
+			u.traversingConnection.Nodes == connIDs {
+			u.setTraversingConnection(nil)
+			u.traversingProgress = 0
+		}
+	}
+
+	for i, conn := range b.Connections {
+		if conn.Nodes == connIDs {
+			b.Connections = append(b.Connections[:i], b.Connections[i+1:]...)
+			break
+		}
+	}
+
+	for _, id := range []int{connIDs.Node1, connIDs.Node2} {
+		conns := b.adjacency[id]
+
+		for i, conn := range conns {
+			if conn.Nodes == connIDs {
+				b.adjacency[id] = append(conns[:i:i], conns[i+1:]...)
+				break
+			}
+		}
+	}
+
+	delete(b.connected, connIDs)
+	delete(b.traffic, connIDs)
+	b.invalidatePaths()
+}
+
+// rerouteUnits finds new paths for units whose path is no longer in the
+// graph.
+func (b *Blob) rerouteUnits() {
+	for _, id := range SortedKeys(b.Units) {
+		u := b.Units[id]
+
+		if u.traversingPath != nil && !u.pathValid() {
+			u.reroute()
+		}
+	}
+}
+
+// removeID removes a node id from consumer or producer priorities.
+func removeID(priorities map[ResourceType]map[int][]int, id int) {
+	for _, res := range SortedKeys(priorities) {
+		for priority, ids := range priorities[res] {
+			kept := make([]int, 0, len(ids))
+
+			for _, nodeID := range ids {
+				if nodeID != id {
+					kept = append(kept, nodeID)
+				}
+			}
+
+			priorities[res][priority] = kept
+		}
+	}
+}
+
+func (b *Blob) indexConnection(conn *Connection) {
+	b.connected[conn.Nodes] = true
+
+	for _, id := range []int{conn.Nodes.Node1, conn.Nodes.Node2} {
+		b.adjacency[id] = append(b.adjacency[id], conn)
+	}
+}
+
+// connectionConfig returns the config of a connection type, connection types
+// missing from config behave like a plain tunnel.
+func (b *Blob) connectionConfig(connType ConnectionType) *ConnectionConfig {
+	conf, ok := b.conf.Connections[connType]
+	if !ok {
+		return defaultConnectionConfig
+	}
+
+	return conf
+}
+
+// connectionFull returns whether the connection is at its unit capacity.
+func (b *Blob) connectionFull(conn *Connection) bool {
+	capacity := b.connectionConfig(conn.Type).Capacity
+
+	return capacity > 0 && b.traffic[conn.Nodes] >= capacity
+}
+
 func (b *Blob) GetClosestNode(pos pixel.Vec) (int, error) {
 	dist := math.Inf(1)
 
 	var closestID int
 	var found bool
 
-	for _, node := range b.Nodes {
+	for _, id := range SortedKeys(b.Nodes) {
+		node := b.Nodes[id]
 		d := pos.Sub(node.pos).Len()
 
 		if d < dist {
@@ -284,96 +817,111 @@ func (b *Blob) GetClosestNode(pos pixel.Vec) (int, error) {
 	return closestID, nil
 }
 
-func (b *Blob) Dijkstra(startNodeID, targetNodeID int) ([]int, error) {
-	if startNodeID == targetNodeID {
-		return nil, nil
-	}
-
-	pathID := fmt.Sprintf("%d-%d", startNodeID, targetNodeID)
-
-	path, ok := b.pathCache[pathID]
-	if ok {
-		return path, nil
+// GetNodeAt returns the id of the node covering pos, the closest one if nodes
+// overlap.
+func (b *Blob) GetNodeAt(pos pixel.Vec) (int, error) {
+	id, err := b.GetClosestNode(pos)
+	if err != nil {
+		return 0, err
 	}
 
-	dist := make(map[int]float64)
-	prev := make(map[int]int)
-
-	for _, node := range b.Nodes {
-		dist[node.id] = math.Inf(1)
+	node := b.Nodes[id]
+	if pos.Sub(node.pos).Len() > node.conf.Radius {
+		return 0, errors.New("no node found")
 	}
 
-	dist[startNodeID] = 0
-
-	for len(dist) > 0 {
-		var nodeID int
-		minDist := math.Inf(1)
+	return id, nil
+}
 
-		// find node id with shortest distance
-		for nID, l := range dist {
-			if l <= minDist {
-				nodeID = nID
-				minDist = l
-			}
-		}
+// GetUnitAt returns the unit closest to pos, if it is within maxDist of it.
+func (b *Blob) GetUnitAt(pos pixel.Vec, maxDist float64) (int, error) {
+	var (
+		closest int
+		found   bool
+	)
 
-		conns := b.GetNodeConnections(nodeID)
-		if len(conns) == 0 {
+	for _, id := range SortedKeys(b.Units) {
+		d := b.Units[id].Pos().Sub(pos).Len()
+		if d <= maxDist {
+			closest = id
+			maxDist = d
+			found = true
 		}
+	}
 
-		for _, conn := range conns {
-			neighborID := conn.Nodes.Opposite(nodeID)
-
-			// not in priority queue
-			if _, ok := dist[neighborID]; !ok {
-				continue
-			}
+	if !found {
+		return 0, errors.New("no unit found")
+	}
 
-			alt := dist[nodeID] + conn.Length
+	return closest, nil
+}
 
-			if dist[neighborID] > alt {
-				dist[neighborID] = alt
-				prev[neighborID] = nodeID
-			}
+// HasRoom returns whether a node of the type fits at pos, keeping at least
+// spacing between its edge and the edges of other nodes. Nodes in ignore,
+// such as the ones being moved, are not in the way.
+func (b *Blob) HasRoom(
+	pos pixel.Vec,
+	nodeType NodeType,
+	spacing float64,
+	ignore map[int]bool,
+) bool {
+	conf, ok := b.conf.Nodes[nodeType]
+	if !ok {
+		return false
+	}
 
+	for id, node := range b.Nodes {
+		if ignore[id] {
+			continue
 		}
 
-		// delete looked at node
-		delete(dist, nodeID)
+		if pos.Sub(node.pos).Len() < conf.Radius+node.conf.Radius+spacing {
+			return false
+		}
 	}
 
-	path = []int{}
-	ok = false
-
-	node := targetNodeID
+	return true
+}
 
-	for {
-		path = append(path, node)
+// GetConnectionAt returns the connection closest to pos, if it is within
+// maxDist of it.
+func (b *Blob) GetConnectionAt(
+	pos pixel.Vec,
+	maxDist float64,
+) (*Connection, error) {
+	var closest *Connection
 
-		if node == startNodeID {
-			break
-		}
+	for _, conn := range b.Connections {
+		line := pixel.L(
+			b.Nodes[conn.Nodes.Node1].pos,
+			b.Nodes[conn.Nodes.Node2].pos,
+		)
 
-		node, ok = prev[node]
-		if !ok {
-			return nil, errors.New("no path found")
+		d := pos.Sub(line.Closest(pos)).Len()
+		if d <= maxDist {
+			closest = conn
+			maxDist = d
 		}
 	}
 
-	path = ReverseSlice(path)[1:]
-
-	b.pathCache[pathID] = path
+	if closest == nil {
+		return nil, errors.New("no connection found")
+	}
 
-	return path, nil
+	return closest, nil
 }
 
+// priorityLevels is the number of consumer and producer priorities, 0 is
+// served first.
+const priorityLevels = 10
+
 func (b *Blob) GetConsumerNodeID(resourceType ResourceType) (int, error) {
 	priorities, ok := b.consumers[resourceType]
 	if !ok {
 		return 0, errors.New("no consumer found")
 	}
 
-	for priority := 0; priority < 10; priority++ {
+	for priority := 0; priority < priorityLevels; priority++ {
 		ids, ok := priorities[priority]
 		if !ok {
 			continue
@@ -416,7 +964,7 @@ func (b *Blob) GetProducerNodeID() (int, ResourceType, error) {
 		found           bool
 	)
 
-	for res := range b.producers {
+	for _, res := range SortedKeys(b.producers) {
 		nodeID, c, err := b.GetResourceProducerNodeID(res)
 		if err != nil {
 			continue
@@ -445,7 +993,7 @@ func (b *Blob) GetResourceProducerNodeID(resourceType ResourceType) (int, int, e
 		return 0, 0, errors.New("no producer found")
 	}
 
-	for priority := 0; priority < 10; priority++ {
+	for priority := 0; priority < priorityLevels; priority++ {
 		ids, ok := priorities[priority]
 		if !ok {
 			continue
@@ -486,6 +1034,7 @@ func (b *Blob) RemoveResources() {
 
 func (b *Blob) RemoveUnits() {
 	b.Units = make(map[int]*Unit)
+	b.traffic = make(map[ConnectionIDs]int)
 	b.jobs.Reset()
 }
 
@@ -493,6 +1042,8 @@ type JobQueue struct {
 	occupied  map[int]*Job
 	available map[int]*Job
 	halted    map[int]*Job
+
+	blob *Blob
 }
 
 type JobQueueJSON struct {
@@ -506,6 +1057,7 @@ func NewJobQueue(jqj *JobQueueJSON, conf *BlobConfig, blob *Blob) *JobQueue {
 		occupied:  make(map[int]*Job),
 		available: make(map[int]*Job),
 		halted:    make(map[int]*Job),
+		blob:      blob,
 	}
 
 	if jqj == nil {
@@ -569,12 +1121,7 @@ func (jq *JobQueue) getAvailable() (*Job, error) {
 		return nil, errors.New("no available job")
 	}
 
-	var job *Job
-
-	for _, j := range jq.available {
-		job = j
-		break
-	}
+	job := jq.available[SortedKeys(jq.available)[0]]
 
 	delete(jq.available, job.id)
 
@@ -586,12 +1133,7 @@ func (jq *JobQueue) getHalted() (*Job, error) {
 		return nil, errors.New("no halted job")
 	}
 
-	var job *Job
-
-	for _, j := range jq.halted {
-		job = j
-		break
-	}
+	job := jq.halted[SortedKeys(jq.halted)[0]]
 
 	delete(jq.halted, job.id)
 
@@ -600,7 +1142,7 @@ func (jq *JobQueue) getHalted() (*Job, error) {
 
 func (jq *JobQueue) Complete(job *Job) {
 	if job == nil {
-		fmt.Println("attempt to complete nil job")
+		jq.blob.emit(&Warning{Message: "attempt to complete nil job"})
 		return
 	}
 
@@ -609,10 +1151,18 @@ func (jq *JobQueue) Complete(job *Job) {
 	err := job.Complete()
 	if err != nil {
 		jq.halted[job.id] = job
+		jq.blob.emit(job.halted())
+
 		return
 	}
 
 	jq.available[job.id] = job
+
+	jq.blob.emit(&JobCompleted{
+		JobID:   job.id,
+		NodeID:  job.nodeID,
+		JobType: job.jobType,
+	})
 }
 
 func (jq *JobQueue) Add(jobs ...*Job) {
@@ -623,19 +1173,90 @@ func (jq *JobQueue) Add(jobs ...*Job) {
 
 func (jq *JobQueue) Halt(job *Job) {
 	if job == nil {
-		fmt.Println("attempt to halt nil job")
+		jq.blob.emit(&Warning{Message: "attempt to halt nil job"})
 		return
 	}
 
 	_, ok := jq.occupied[job.id]
 	if !ok {
-		fmt.Println("attempt to halt job not in queue")
+		jq.blob.emit(&Warning{Message: "attempt to halt job not in queue"})
 		return
 	}
 
 	delete(jq.occupied, job.id)
 
 	jq.halted[job.id] = job
+
+	jq.blob.emit(job.halted())
+}
+
+// NodeJobs returns the jobs of a node in all queues, ordered by their IDs.
+func (jq *JobQueue) NodeJobs(nodeID int) []*Job {
+	var jobs []*Job
+
+	for _, job := range jq.all() {
+		if job.nodeID == nodeID {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+// all returns the jobs in all queues, ordered by their IDs.
+func (jq *JobQueue) all() []*Job {
+	var jobs []*Job
+
+	for _, queue := range []map[int]*Job{jq.occupied, jq.available, jq.halted} {
+		for _, job := range queue {
+			jobs = append(jobs, job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].id < jobs[j].id })
+
+	return jobs
+}
+
+// State returns which queue the job is in.
+func (jq *JobQueue) State(job *Job) JobState {
+	switch {
+	case jq.occupied[job.id] != nil:
+		return JobStateOccupied
+	case jq.halted[job.id] != nil:
+		return JobStateHalted
+	default:
+		return JobStateAvailable
+	}
+}
+
+// Remove removes a job from whichever queue it is in.
+func (jq *JobQueue) Remove(job *Job) {
+	delete(jq.occupied, job.id)
+	delete(jq.available, job.id)
+	delete(jq.halted, job.id)
+}
+
+// RemoveNode removes the jobs of a node from all queues.
+func (jq *JobQueue) RemoveNode(nodeID int) {
+	for _, queue := range []map[int]*Job{jq.occupied, jq.available, jq.halted} {
+		for id, job := range queue {
+			if job.nodeID == nodeID {
+				delete(queue, id)
+			}
+		}
+	}
+}
+
+// Release puts a job back to the available jobs, if it is occupied.
+func (jq *JobQueue) Release(job *Job) {
+	if _, ok := jq.occupied[job.id]; !ok {
+		return
+	}
+
+	delete(jq.occupied, job.id)
+
+	jq.available[job.id] = job
 }
 
 func (jq *JobQueue) Reset() {
@@ -645,8 +1266,26 @@ func (jq *JobQueue) Reset() {
 	}
 }
 
-func RandomSliceElement[T any](slice []T) T {
-	return slice[rand.Intn(len(slice))]
+func RandomSliceElement[T any](r *rand.Rand, slice []T) T {
+	return slice[r.Intn(len(slice))]
+}
+
+type ordered interface {
+	~int | ~int64 | ~float64 | ~string
+}
+
+// SortedKeys returns keys of the map in ascending order. Use it wherever map
+// iteration order could affect the outcome of the simulation.
+func SortedKeys[K ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return keys
 }
 
 func ReverseSlice[T any](slice []T) []T {
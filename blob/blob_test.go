@@ -0,0 +1,361 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+var graphConf = &BlobConfig{
+	Nodes: map[NodeType]*NodeConfig{
+		"farm": {
+			Radius:           5,
+			ResourceCapacity: 5,
+			Produces:         map[ResourceType]int{"moss": 0},
+			Jobs:             []JobType{"farming"},
+		},
+		"big_farm": {
+			Radius:           10,
+			ResourceCapacity: 10,
+			Produces:         map[ResourceType]int{"moss": 0},
+			Jobs:             []JobType{"farming"},
+		},
+		"store": {
+			Radius:           5,
+			ResourceCapacity: 5,
+			Consumes:         map[ResourceType]int{"moss": 0},
+		},
+	},
+	Jobs: map[JobType]*JobConfig{
+		"farming": {Recipe: "grow", ProductionSpeed: 1},
+	},
+	Recipes: map[RecipeType]*RecipeConfig{
+		"grow": {Outputs: map[ResourceType]int{"moss": 1}, Duration: 10},
+	},
+	Resources: map[ResourceType]*ResourceConfig{"moss": {}},
+	Unit: &UnitConfig{
+		TraversalSpeed: 2,
+		HungerRate:     0.01,
+		MaxHunger:      1e9,
+	},
+}
+
+// lineBlob returns a blob of farms and stores taking turns, each connected to
+// the next, with a unit on every node.
+func lineBlob(size int) *Blob {
+	b := NewBlob(&BlobJSON{Seed: 1}, graphConf)
+
+	for i := 0; i < size; i++ {
+		nodeType := NodeType("farm")
+		if i%2 == 1 {
+			nodeType = "store"
+		}
+
+		id := b.AddNode(pixel.V(float64(i*60), 0), nodeType)
+		b.AddUnit(id)
+
+		if i > 0 {
+			b.Connect(id-1, id, "")
+		}
+	}
+
+	return b
+}
+
+// checkGraph checks that the blob's indexes agree with its nodes and
+// connections, and that units only refer to what is still there.
+func checkGraph(t *testing.T, b *Blob) {
+	t.Helper()
+
+	if errs := b.ToJSON().Validate(b.conf); len(errs) > 0 {
+		t.Fatalf("invalid blob: %v", errs)
+	}
+
+	adjacent := 0
+
+	for id, conns := range b.adjacency {
+		if b.Nodes[id] == nil {
+			t.Fatalf("adjacency of removed node %d", id)
+		}
+
+		for _, conn := range conns {
+			if conn.Nodes.Node1 != id && conn.Nodes.Node2 != id {
+				t.Fatalf("connection %v indexed under node %d", conn.Nodes, id)
+			}
+
+			if !b.connected[conn.Nodes] {
+				t.Fatalf("indexed connection %v is not connected", conn.Nodes)
+			}
+		}
+
+		adjacent += len(conns)
+	}
+
+	if adjacent != 2*len(b.Connections) ||
+		len(b.connected) != len(b.Connections) {
+		t.Fatalf(
+			"%d connections, %d adjacent and %d connected",
+			len(b.Connections),
+			adjacent,
+			len(b.connected),
+		)
+	}
+
+	for _, job := range b.jobs.all() {
+		node := b.Nodes[job.nodeID]
+		if node == nil {
+			t.Fatalf("job %d of removed node %d", job.id, job.nodeID)
+		}
+
+		found := false
+		for _, jobType := range node.conf.Jobs {
+			found = found || jobType == job.jobType
+		}
+
+		if !found {
+			t.Fatalf("node %d has no %s job", node.id, job.jobType)
+		}
+	}
+
+	for name, priorities := range map[string]map[ResourceType]map[int][]int{
+		"consumer": b.consumers,
+		"producer": b.producers,
+	} {
+		for res, levels := range priorities {
+			for _, ids := range levels {
+				for _, id := range ids {
+					node := b.Nodes[id]
+					if node == nil {
+						t.Fatalf("removed node %d is a %s", id, name)
+					}
+
+					_, consumes := node.conf.Consumes[res]
+					_, produces := node.conf.Produces[res]
+
+					if name == "consumer" && !consumes ||
+						name == "producer" && !produces {
+						t.Fatalf("node %d is no %s of %s", id, name, res)
+					}
+				}
+			}
+		}
+	}
+
+	traffic := make(map[ConnectionIDs]int)
+
+	for _, u := range b.Units {
+		if b.Nodes[u.nodeID] == nil {
+			t.Fatalf("unit %d on removed node %d", u.id, u.nodeID)
+		}
+
+		if conn := u.traversingConnection; conn != nil {
+			if !b.connected[conn.Nodes] {
+				t.Fatalf("unit %d on removed connection %v", u.id, conn.Nodes)
+			}
+
+			if conn.Nodes.Node1 != u.nodeID && conn.Nodes.Node2 != u.nodeID {
+				t.Fatalf(
+					"unit %d left node %d by %v",
+					u.id,
+					u.nodeID,
+					conn.Nodes,
+				)
+			}
+
+			traffic[conn.Nodes]++
+		}
+
+		if u.traversingPath != nil && !u.pathValid() {
+			t.Fatalf("unit %d has an invalid path", u.id)
+		}
+
+		if u.job != nil && b.jobs.State(u.job) != JobStateOccupied {
+			t.Fatalf("unit %d does job %d that is not occupied", u.id, u.job.id)
+		}
+	}
+
+	if len(traffic) != len(b.traffic) {
+		t.Fatalf("traffic %v, expected %v", b.traffic, traffic)
+	}
+
+	for connIDs, count := range traffic {
+		if b.traffic[connIDs] != count {
+			t.Fatalf("traffic %v, expected %v", b.traffic, traffic)
+		}
+	}
+}
+
+// run updates the blob, checking its graph after every tick.
+func run(t *testing.T, b *Blob, ticks int) {
+	t.Helper()
+
+	for i := 0; i < ticks; i++ {
+		b.Update()
+		checkGraph(t, b)
+	}
+}
+
+func TestRemoveNodeKeepsGraph(t *testing.T) {
+	for id := 0; id < 5; id++ {
+		b := lineBlob(5)
+		run(t, b, 150) // units are working and carrying
+
+		err := b.RemoveNode(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b.Nodes[id] != nil || b.adjacency[id] != nil {
+			t.Fatalf("node %d is still there", id)
+		}
+
+		for _, u := range b.Units {
+			if u.heading(id) {
+				t.Fatalf("unit %d still heads to node %d", u.id, id)
+			}
+		}
+
+		checkGraph(t, b)
+		run(t, b, 200)
+	}
+}
+
+func TestDisconnectKeepsGraph(t *testing.T) {
+	b := lineBlob(3)
+	u := b.AddUnit(0)
+	u.procedure = []*ProcedureStep{{stepType: TraverseTo, nodeID: 2}}
+	run(t, b, 5)
+
+	if u.traversingConnection == nil {
+		t.Fatal("expected the unit to be on a connection")
+	}
+
+	connIDs := u.traversingConnection.Nodes
+
+	err := b.Disconnect(connIDs.Node1, connIDs.Node2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.traversingConnection != nil &&
+		u.traversingConnection.Nodes == connIDs {
+		t.Fatal("unit is still on the removed connection")
+	}
+
+	if b.traffic[connIDs] != 0 {
+		t.Fatalf("traffic %d on the removed connection", b.traffic[connIDs])
+	}
+
+	checkGraph(t, b)
+	run(t, b, 200)
+
+	if err := b.Disconnect(connIDs.Node1, connIDs.Node2); err == nil {
+		t.Fatal("expected an error disconnecting twice")
+	}
+}
+
+func TestEnterRemovedConnection(t *testing.T) {
+	b := lineBlob(3)
+
+	_, err := b.Connect(0, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := b.AddUnit(0)
+	u.SetTraversalPath([]int{1, 2})
+	u.SetCurrentProcedureStep(Traverse)
+
+	// removed without rerouting, the unit finds out when it gets there
+	b.removeConnection(NewConnectionIDs(1, 2))
+
+	for i := 0; u.nodeID != 1; i++ {
+		if i > 100 {
+			t.Fatal("expected the unit to get to node 1")
+		}
+
+		b.Update()
+	}
+
+	if u.traversingConnection == nil ||
+		u.traversingConnection.Nodes != NewConnectionIDs(0, 1) {
+		t.Fatal("expected the unit to go round by node 0")
+	}
+
+	checkGraph(t, b)
+	run(t, b, 200)
+}
+
+func TestSetNodeTypeKeepsGraph(t *testing.T) {
+	b := lineBlob(3)
+	run(t, b, 150)
+
+	err := b.SetNodeType(0, "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.jobs.NodeJobs(0)) != 0 {
+		t.Fatal("expected the farm's jobs to be removed")
+	}
+
+	for _, u := range b.Units {
+		if u.job != nil && u.job.nodeID == 0 {
+			t.Fatalf("unit %d still does a removed job", u.id)
+		}
+	}
+
+	checkGraph(t, b)
+	run(t, b, 100)
+
+	err = b.SetNodeType(0, "farm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.jobs.NodeJobs(0)) != 1 {
+		t.Fatal("expected the farm's job to be added back")
+	}
+
+	checkGraph(t, b)
+	run(t, b, 100)
+}
+
+func TestRemoveLastNode(t *testing.T) {
+	b := NewBlob(&BlobJSON{}, graphConf)
+	id := b.AddNode(pixel.V(0, 0), "store")
+	u := b.AddUnit(id)
+	u.resource = "moss"
+
+	all := events(b)
+
+	err := b.RemoveNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.Units) != 0 {
+		t.Fatal("expected the unit to die")
+	}
+
+	var types []EventType
+	for _, event := range *all {
+		types = append(types, event.Type())
+	}
+
+	expected := []EventType{
+		EventResourcePiled,
+		EventUnitDied,
+		EventResourceLost,
+	}
+
+	if len(types) != len(expected) {
+		t.Fatalf("events %v, expected %v", types, expected)
+	}
+
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("events %v, expected %v", types, expected)
+		}
+	}
+}
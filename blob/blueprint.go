@@ -0,0 +1,242 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Blueprint is a sub-graph of nodes and the connections between them, that
+// can be placed anywhere in a blob. Node positions are relative to the
+// blueprint's center and connections refer to the blueprint's node IDs.
+type Blueprint struct {
+	Name        string           `json:"name"`
+	Nodes       []*BlueprintNode `json:"nodes"`
+	Connections []*Connection    `json:"connections"`
+}
+
+type BlueprintNode struct {
+	ID       int       `json:"id"`
+	Pos      pixel.Vec `json:"pos"`
+	NodeType NodeType  `json:"node_type"`
+}
+
+// Blueprint returns a blueprint of the nodes and the connections between
+// them, centered on the nodes' average position.
+func (b *Blob) Blueprint(ids []int) (*Blueprint, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("no nodes")
+	}
+
+	var center pixel.Vec
+
+	in := make(map[int]bool, len(ids))
+
+	for _, id := range ids {
+		node, ok := b.Nodes[id]
+		if !ok {
+			return nil, fmt.Errorf("node %d not found", id)
+		}
+
+		center = center.Add(node.pos)
+		in[id] = true
+	}
+
+	center = center.Scaled(1 / float64(len(in)))
+
+	bp := &Blueprint{}
+
+	for _, id := range SortedKeys(in) {
+		node := b.Nodes[id]
+
+		bp.Nodes = append(bp.Nodes, &BlueprintNode{
+			ID:       id,
+			Pos:      node.pos.Sub(center),
+			NodeType: node.nodeType,
+		})
+	}
+
+	for _, conn := range b.Connections {
+		if in[conn.Nodes.Node1] && in[conn.Nodes.Node2] {
+			c := *conn
+			bp.Connections = append(bp.Connections, &c)
+		}
+	}
+
+	return bp, nil
+}
+
+// BuildCost returns the total build cost of the blueprint's connections.
+func (bp *Blueprint) BuildCost(conf *BlobConfig) map[ResourceType]int {
+	cost := make(map[ResourceType]int)
+
+	for _, conn := range bp.Connections {
+		connType := conn.Type
+		if connType == "" {
+			connType = conf.DefaultConnection
+		}
+
+		connConf, ok := conf.Connections[connType]
+		if !ok {
+			continue
+		}
+
+		for res, count := range connConf.BuildCost {
+			cost[res] += count
+		}
+	}
+
+	return cost
+}
+
+// PlaceBlueprint builds the blueprint centered on pos and returns the IDs of
+// the new nodes in the order of the blueprint's nodes. The build cost of its
+// connections is paid up front, nothing is built if it is not in stock.
+func (b *Blob) PlaceBlueprint(bp *Blueprint, pos pixel.Vec) ([]int, error) {
+	ids := make(map[int]int, len(bp.Nodes)) // blueprint to blob node ids
+
+	for i, node := range bp.Nodes {
+		if b.conf.Nodes[node.NodeType] == nil {
+			return nil, fmt.Errorf(
+				"node %d: unknown node type %q",
+				i,
+				node.NodeType,
+			)
+		}
+
+		if _, ok := ids[node.ID]; ok {
+			return nil, fmt.Errorf("node %d: duplicate id %d", i, node.ID)
+		}
+
+		ids[node.ID] = -1
+	}
+
+	for i, conn := range bp.Connections {
+		_, ok1 := ids[conn.Nodes.Node1]
+		_, ok2 := ids[conn.Nodes.Node2]
+
+		if !ok1 || !ok2 || conn.Nodes.Node1 == conn.Nodes.Node2 {
+			return nil, fmt.Errorf("connection %d: invalid nodes", i)
+		}
+
+		connType := conn.Type
+		if connType == "" {
+			connType = b.conf.DefaultConnection
+		}
+
+		_, ok := b.conf.Connections[connType]
+		if !ok && connType != b.conf.DefaultConnection {
+			return nil, fmt.Errorf(
+				"connection %d: unknown connection type %q",
+				i,
+				connType,
+			)
+		}
+	}
+
+	err := b.payBuildCost(bp.BuildCost(b.conf))
+	if err != nil {
+		return nil, err
+	}
+
+	placed := make([]int, 0, len(bp.Nodes))
+
+	for _, node := range bp.Nodes {
+		id := b.AddNode(pos.Add(node.Pos), node.NodeType)
+
+		ids[node.ID] = id
+		placed = append(placed, id)
+	}
+
+	for _, conn := range bp.Connections {
+		connIDs := NewConnectionIDs(
+			ids[conn.Nodes.Node1],
+			ids[conn.Nodes.Node2],
+		)
+		if b.connected[connIDs] {
+			continue
+		}
+
+		connType := conn.Type
+		if connType == "" {
+			connType = b.conf.DefaultConnection
+		}
+
+		b.addConnection(connIDs, connType)
+	}
+
+	return placed, nil
+}
+
+// NodesIn returns the IDs of nodes whose center is in the rectangle, in
+// ascending order.
+func (b *Blob) NodesIn(rect pixel.Rect) []int {
+	var ids []int
+
+	for _, id := range SortedKeys(b.Nodes) {
+		if rect.Contains(b.Nodes[id].pos) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// Rotated returns a copy of the blueprint rotated by angle radians around its
+// center.
+func (bp *Blueprint) Rotated(angle float64) *Blueprint {
+	rotated := &Blueprint{
+		Name:        bp.Name,
+		Connections: bp.Connections,
+	}
+
+	for _, node := range bp.Nodes {
+		n := *node
+		n.Pos = n.Pos.Rotated(angle)
+
+		rotated.Nodes = append(rotated.Nodes, &n)
+	}
+
+	return rotated
+}
+
+// ClosestNodes returns the closest pair of a node in ids and a node not in
+// ids, such as a placed blueprint and the graph it is placed next to.
+func (b *Blob) ClosestNodes(ids []int) (int, int, error) {
+	in := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		in[id] = true
+	}
+
+	dist := math.Inf(1)
+
+	var inside, outside int
+
+	for _, id := range SortedKeys(in) {
+		node, ok := b.Nodes[id]
+		if !ok {
+			continue
+		}
+
+		for _, otherID := range SortedKeys(b.Nodes) {
+			if in[otherID] {
+				continue
+			}
+
+			d := node.pos.Sub(b.Nodes[otherID].pos).Len()
+			if d < dist {
+				dist = d
+				inside = id
+				outside = otherID
+			}
+		}
+	}
+
+	if math.IsInf(dist, 1) {
+		return 0, 0, errors.New("no node found")
+	}
+
+	return inside, outside, nil
+}
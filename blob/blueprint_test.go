@@ -0,0 +1,148 @@
+package blob
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// roadConf is graphConf with connections costing a moss each.
+func roadConf() *BlobConfig {
+	conf := *graphConf
+	conf.Connections = map[ConnectionType]*ConnectionConfig{
+		"road": {
+			SpeedMultiplier: 1,
+			BuildCost:       map[ResourceType]int{"moss": 1},
+		},
+	}
+	conf.DefaultConnection = "road"
+
+	return &conf
+}
+
+func pairBlueprint(connType ConnectionType) *Blueprint {
+	return &Blueprint{
+		Nodes: []*BlueprintNode{
+			{ID: 3, Pos: pixel.V(-30, 0), NodeType: "farm"},
+			{ID: 8, Pos: pixel.V(30, 0), NodeType: "store"},
+		},
+		Connections: []*Connection{
+			{Nodes: NewConnectionIDs(3, 8), Type: connType},
+		},
+	}
+}
+
+func TestBlueprintPaste(t *testing.T) {
+	b := lineBlob(4)
+
+	bp, err := b.Blueprint([]int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bp.Nodes) != 2 || len(bp.Connections) != 1 ||
+		bp.Connections[0].Nodes != NewConnectionIDs(1, 2) {
+		t.Fatalf("expected nodes 1 and 2 connected only to each other")
+	}
+
+	pos := pixel.V(90, 200)
+
+	placed, err := b.PlaceBlueprint(bp, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(placed) != 2 || placed[0] != 4 || placed[1] != 5 {
+		t.Fatalf("placed nodes %v, expected new ids 4 and 5", placed)
+	}
+
+	for i, id := range placed {
+		node := b.Nodes[id]
+
+		if node.nodeType != bp.Nodes[i].NodeType ||
+			node.pos != pos.Add(bp.Nodes[i].Pos) {
+			t.Fatalf("node %d is not a copy of blueprint node %d", id, i)
+		}
+
+		if len(b.adjacency[id]) != 1 {
+			t.Fatalf("node %d has %d connections", id, len(b.adjacency[id]))
+		}
+	}
+
+	if !b.connected[NewConnectionIDs(4, 5)] || len(b.Connections) != 4 {
+		t.Fatal("expected only the internal connection to be copied")
+	}
+
+	inside, outside, err := b.ClosestNodes(placed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inside != 4 || outside != 1 {
+		t.Fatalf("closest nodes %d and %d, expected 4 and 1", inside, outside)
+	}
+
+	checkGraph(t, b)
+}
+
+func TestBlueprintRotated(t *testing.T) {
+	bp := pairBlueprint("")
+	rotated := bp.Rotated(math.Pi / 2)
+
+	if len(rotated.Connections) != 1 {
+		t.Fatal("expected the connection to be kept")
+	}
+
+	expected := []pixel.Vec{pixel.V(0, -30), pixel.V(0, 30)}
+
+	for i, node := range rotated.Nodes {
+		if node.ID != bp.Nodes[i].ID ||
+			node.Pos.Sub(expected[i]).Len() > 1e-9 {
+			t.Fatalf("node %d at %v, expected %v", i, node.Pos, expected[i])
+		}
+	}
+
+	if bp.Nodes[0].Pos != pixel.V(-30, 0) {
+		t.Fatal("expected the blueprint to be left as it was")
+	}
+}
+
+func TestPlaceBlueprintCost(t *testing.T) {
+	b := NewBlob(&BlobJSON{Seed: 1}, roadConf())
+	farm := b.AddNode(pixel.V(0, 0), "farm")
+
+	for _, connType := range []ConnectionType{"", "rail"} {
+		_, err := b.PlaceBlueprint(pairBlueprint(connType), pixel.V(0, 200))
+		if err == nil {
+			t.Fatalf("expected %q connection not to be built", connType)
+		}
+
+		if len(b.Nodes) != 1 || len(b.Connections) != 0 {
+			t.Fatal("expected nothing to be built")
+		}
+	}
+
+	err := b.Nodes[farm].AddResource("moss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.PlaceBlueprint(pairBlueprint("rail"), pixel.V(0, 200))
+	if err == nil {
+		t.Fatal("expected an unknown connection type to be rejected")
+	}
+
+	_, err = b.PlaceBlueprint(pairBlueprint("road"), pixel.V(0, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.Nodes) != 3 || len(b.Connections) != 1 {
+		t.Fatal("expected the blueprint to be built")
+	}
+
+	if b.Nodes[farm].ResourceCount("moss") != 0 {
+		t.Fatal("expected the build cost to be paid")
+	}
+}
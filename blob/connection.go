@@ -1,5 +1,7 @@
 package blob
 
+import "image/color"
+
 type ConnectionIDs struct {
 	Node1 int `json:"node_1"`
 	Node2 int `json:"node_2"`
@@ -22,6 +24,31 @@ func (c *ConnectionIDs) Opposite(nodeID int) int {
 }
 
 type Connection struct {
-	Nodes  ConnectionIDs `json:"nodes"`
-	Length float64       `json:"length"`
+	Nodes  ConnectionIDs  `json:"nodes"`
+	Length float64        `json:"length"`
+	Type   ConnectionType `json:"type"`
+}
+
+// ConnectionType names a connection configuration, connections without a type
+// are of the config's default connection type.
+type ConnectionType string
+
+// ConnectionConfig describes a connection type. Units traverse it
+// SpeedMultiplier times as fast as they walk, at most Capacity of them at a
+// time, the others wait at the node. A capacity of 0 is unlimited. BuildCost
+// is taken from the stock of nodes when connecting.
+type ConnectionConfig struct {
+	SpeedMultiplier float64              `json:"speed_multiplier"`
+	Capacity        int                  `json:"capacity"`
+	BuildCost       map[ResourceType]int `json:"build_cost"`
+	Menu            *MenuConfig          `json:"menu"`
+	Color           color.RGBA           `json:"color"`
+	Thickness       float64              `json:"thickness"`
+}
+
+// defaultConnectionConfig is used for connections whose type is not in config.
+var defaultConnectionConfig = &ConnectionConfig{
+	SpeedMultiplier: 1,
+	Color:           color.RGBA{255, 255, 255, 255},
+	Thickness:       8,
 }
@@ -0,0 +1,159 @@
+package blob
+
+import "github.com/faiface/pixel"
+
+type EventType string
+
+const (
+	EventUnitSpawned      EventType = "unit_spawned"
+	EventUnitDied         EventType = "unit_died"
+	EventResourceProduced EventType = "resource_produced"
+	EventResourceConsumed EventType = "resource_consumed"
+	EventResourcePickedUp EventType = "resource_picked_up"
+	EventResourceDropped  EventType = "resource_dropped"
+	EventResourcePiled    EventType = "resource_piled"
+	EventPileCollected    EventType = "pile_collected"
+	EventResourceLost     EventType = "resource_lost"
+	EventJobCompleted     EventType = "job_completed"
+	EventJobHalted        EventType = "job_halted"
+	EventNodeBuilt        EventType = "node_built"
+	EventWarning          EventType = "warning"
+)
+
+// Event is something that happened in the blob. Subscribers switch on the
+// concrete type for its details.
+type Event interface {
+	Type() EventType
+}
+
+type UnitSpawned struct {
+	UnitID int `json:"unit_id"`
+	NodeID int `json:"node_id"`
+}
+
+type UnitDied struct {
+	UnitID int     `json:"unit_id"`
+	NodeID int     `json:"node_id"`
+	Hunger float64 `json:"hunger"`
+}
+
+// ResourceProduced is the output of a recipe, run by a node or a job.
+type ResourceProduced struct {
+	NodeID   int          `json:"node_id"`
+	Resource ResourceType `json:"resource"`
+	Count    int          `json:"count"`
+	Recipe   RecipeType   `json:"recipe"`
+}
+
+// ResourceConsumed is a resource used up, as the input of a recipe or eaten
+// by a unit.
+type ResourceConsumed struct {
+	NodeID   int          `json:"node_id"`
+	Resource ResourceType `json:"resource"`
+	Count    int          `json:"count"`
+	Recipe   RecipeType   `json:"recipe"` // empty when eaten
+}
+
+type ResourcePickedUp struct {
+	UnitID   int          `json:"unit_id"`
+	NodeID   int          `json:"node_id"`
+	Resource ResourceType `json:"resource"`
+}
+
+type ResourceDropped struct {
+	UnitID   int          `json:"unit_id"`
+	NodeID   int          `json:"node_id"`
+	Resource ResourceType `json:"resource"`
+}
+
+// ResourcePiled is a carried resource left on the ground, because nothing
+// could take it or its carrier died.
+type ResourcePiled struct {
+	UnitID   int          `json:"unit_id"`
+	NodeID   int          `json:"node_id"`
+	PileID   int          `json:"pile_id"`
+	Resource ResourceType `json:"resource"`
+	Reason   string       `json:"reason"`
+}
+
+type PileCollected struct {
+	UnitID   int          `json:"unit_id"`
+	NodeID   int          `json:"node_id"`
+	PileID   int          `json:"pile_id"`
+	Resource ResourceType `json:"resource"`
+}
+
+// ResourceLost is a pile that vanished with the last node it could be moved
+// to.
+type ResourceLost struct {
+	NodeID   int          `json:"node_id"`
+	PileID   int          `json:"pile_id"`
+	Resource ResourceType `json:"resource"`
+	Reason   string       `json:"reason"`
+}
+
+type JobCompleted struct {
+	JobID   int     `json:"job_id"`
+	NodeID  int     `json:"node_id"`
+	JobType JobType `json:"job_type"`
+}
+
+type JobHalted struct {
+	JobID   int     `json:"job_id"`
+	NodeID  int     `json:"node_id"`
+	JobType JobType `json:"job_type"`
+}
+
+type NodeBuilt struct {
+	NodeID   int       `json:"node_id"`
+	NodeType NodeType  `json:"node_type"`
+	Pos      pixel.Vec `json:"pos"`
+}
+
+// Warning is an inconsistency the blob recovered from, such as a job halted
+// twice.
+type Warning struct {
+	Message string `json:"message"`
+}
+
+func (*UnitSpawned) Type() EventType      { return EventUnitSpawned }
+func (*UnitDied) Type() EventType         { return EventUnitDied }
+func (*ResourceProduced) Type() EventType { return EventResourceProduced }
+func (*ResourceConsumed) Type() EventType { return EventResourceConsumed }
+func (*ResourcePickedUp) Type() EventType { return EventResourcePickedUp }
+func (*ResourceDropped) Type() EventType  { return EventResourceDropped }
+func (*ResourcePiled) Type() EventType    { return EventResourcePiled }
+func (*PileCollected) Type() EventType    { return EventPileCollected }
+func (*ResourceLost) Type() EventType     { return EventResourceLost }
+func (*JobCompleted) Type() EventType     { return EventJobCompleted }
+func (*JobHalted) Type() EventType        { return EventJobHalted }
+func (*NodeBuilt) Type() EventType        { return EventNodeBuilt }
+func (*Warning) Type() EventType          { return EventWarning }
+
+// EventHandler is called with every event and the tick it happened in.
+type EventHandler func(tick int, event Event)
+
+// Subscribe calls the handler with every event from now on, in the order of
+// subscription. The returned function unsubscribes it.
+func (b *Blob) Subscribe(handler EventHandler) func() {
+	id := b.subscribersIdentifier
+	b.subscribersIdentifier++
+
+	b.subscribers[id] = handler
+
+	return func() {
+		delete(b.subscribers, id)
+	}
+}
+
+func (b *Blob) emit(event Event) {
+	if len(b.subscribers) == 0 {
+		return
+	}
+
+	for _, id := range SortedKeys(b.subscribers) {
+		if handler, ok := b.subscribers[id]; ok {
+			handler(b.tick, event)
+		}
+	}
+}
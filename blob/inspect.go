@@ -0,0 +1,285 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/faiface/pixel"
+)
+
+type JobState string
+
+const (
+	JobStateAvailable JobState = "available"
+	JobStateOccupied  JobState = "occupied"
+	JobStateHalted    JobState = "halted"
+)
+
+// NodeInfo is a snapshot of a node, what the editor's inspector shows.
+type NodeInfo struct {
+	ID               int
+	NodeType         NodeType
+	Pos              pixel.Vec
+	Resources        map[ResourceType]int
+	ResourceCapacity int
+	Piles            map[ResourceType]int // left at the node by units
+
+	Recipe             RecipeType
+	ProductionProgress float64
+	RecipeDuration     float64
+
+	// consumer and producer priorities by resource, 0 is served first
+	Consumes map[ResourceType]int
+	Produces map[ResourceType]int
+
+	Jobs  []*JobInfo
+	Units []int // heading to the node, in ascending order
+}
+
+type JobInfo struct {
+	ID      int
+	JobType JobType
+	State   JobState
+	UnitID  int // unit doing the job, if the job is occupied
+	HasUnit bool
+}
+
+func (b *Blob) InspectNode(id int) (*NodeInfo, error) {
+	node, ok := b.Nodes[id]
+	if !ok {
+		return nil, errors.New("node not found")
+	}
+
+	info := &NodeInfo{
+		ID:                 id,
+		NodeType:           node.nodeType,
+		Pos:                node.pos,
+		Resources:          make(map[ResourceType]int),
+		ResourceCapacity:   node.conf.ResourceCapacity,
+		Piles:              make(map[ResourceType]int),
+		Recipe:             node.recipe,
+		ProductionProgress: node.productionProgress,
+		Consumes:           nodePriorities(b.consumers, id),
+		Produces:           nodePriorities(b.producers, id),
+	}
+
+	for res, positions := range node.resources {
+		if len(positions) > 0 {
+			info.Resources[res] = len(positions)
+		}
+	}
+
+	for _, pile := range b.piles {
+		if pile.nodeID == id {
+			info.Piles[pile.resource]++
+		}
+	}
+
+	if recipe, ok := b.conf.Recipes[node.recipe]; ok {
+		info.RecipeDuration = recipe.Duration
+	}
+
+	units := make(map[int]int) // job id to unit id
+
+	for _, unitID := range SortedKeys(b.Units) {
+		u := b.Units[unitID]
+
+		if u.job != nil {
+			units[u.job.id] = unitID
+		}
+
+		if u.heading(id) {
+			info.Units = append(info.Units, unitID)
+		}
+	}
+
+	for _, job := range b.jobs.NodeJobs(id) {
+		unitID, hasUnit := units[job.id]
+
+		info.Jobs = append(info.Jobs, &JobInfo{
+			ID:      job.id,
+			JobType: job.jobType,
+			State:   b.jobs.State(job),
+			UnitID:  unitID,
+			HasUnit: hasUnit,
+		})
+	}
+
+	return info, nil
+}
+
+// SetConsumerPriority changes the priority of a node as a consumer of the
+// resource, it goes last among the consumers of that priority. The node has to
+// consume the resource already. The node's index among the consumers of its
+// previous priority is returned, for RestoreConsumerPriority.
+func (b *Blob) SetConsumerPriority(
+	id int,
+	res ResourceType,
+	priority int,
+) (int, error) {
+	return setPriority(b.consumers, id, res, priority, -1)
+}
+
+// RestoreConsumerPriority puts a node back at the priority and index it had
+// among the consumers of the resource.
+func (b *Blob) RestoreConsumerPriority(
+	id int,
+	res ResourceType,
+	priority int,
+	index int,
+) error {
+	_, err := setPriority(b.consumers, id, res, priority, index)
+	return err
+}
+
+// SetProducerPriority changes the priority of a node as a producer of the
+// resource, it goes last among the producers of that priority. The node has to
+// produce the resource already. The node's index among the producers of its
+// previous priority is returned, for RestoreProducerPriority.
+func (b *Blob) SetProducerPriority(
+	id int,
+	res ResourceType,
+	priority int,
+) (int, error) {
+	return setPriority(b.producers, id, res, priority, -1)
+}
+
+// RestoreProducerPriority puts a node back at the priority and index it had
+// among the producers of the resource.
+func (b *Blob) RestoreProducerPriority(
+	id int,
+	res ResourceType,
+	priority int,
+	index int,
+) error {
+	_, err := setPriority(b.producers, id, res, priority, index)
+	return err
+}
+
+// setPriority moves the node to the priority, inserting it at index among the
+// nodes of that priority, or appending it if index is out of range. It
+// returns the node's index at its previous priority.
+func setPriority(
+	priorities map[ResourceType]map[int][]int,
+	id int,
+	res ResourceType,
+	priority int,
+	index int,
+) (int, error) {
+	if priority < 0 || priority >= priorityLevels {
+		return 0, fmt.Errorf(
+			"priority %d out of range 0 to %d",
+			priority,
+			priorityLevels-1,
+		)
+	}
+
+	current, ok := nodePriorities(priorities, id)[res]
+	if !ok {
+		return 0, fmt.Errorf("node %d has no priority for %q", id, res)
+	}
+
+	prevIndex := 0
+	for i, nodeID := range priorities[res][current] {
+		if nodeID == id {
+			prevIndex = i
+		}
+	}
+
+	if current == priority {
+		return prevIndex, nil
+	}
+
+	removeID(map[ResourceType]map[int][]int{res: priorities[res]}, id)
+
+	ids := priorities[res][priority]
+	if index < 0 || index > len(ids) {
+		index = len(ids)
+	}
+
+	inserted := append(ids[:index:index], id)
+	priorities[res][priority] = append(inserted, ids[index:]...)
+
+	return prevIndex, nil
+}
+
+// nodePriorities returns the priority of the node for each resource it is
+// listed for.
+func nodePriorities(
+	all map[ResourceType]map[int][]int,
+	id int,
+) map[ResourceType]int {
+	found := make(map[ResourceType]int)
+
+	for res, levels := range all {
+		for priority, ids := range levels {
+			for _, nodeID := range ids {
+				if nodeID == id {
+					found[res] = priority
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+// UnitInfo is a snapshot of a unit, what the editor's inspector shows.
+type UnitInfo struct {
+	ID        int
+	NodeID    int
+	Procedure []*ProcedureStepJSON // current step first
+	Resource  ResourceType
+	Retries   int // of finding a consumer for the resource
+
+	Job         *JobJSON // nil without a job
+	JobProgress float64
+	JobDuration float64
+
+	Hunger    float64
+	MaxHunger float64
+
+	// positions along the rest of the unit's path, starting at the unit
+	Path []pixel.Vec
+
+	Trace []*StepTransition // oldest first
+}
+
+func (b *Blob) InspectUnit(id int) (*UnitInfo, error) {
+	u, ok := b.Units[id]
+	if !ok {
+		return nil, errors.New("unit not found")
+	}
+
+	info := &UnitInfo{
+		ID:          id,
+		NodeID:      u.nodeID,
+		Resource:    u.resource,
+		Retries:     u.retries,
+		JobProgress: u.jobProgress,
+		Hunger:      u.hunger,
+		MaxHunger:   u.conf.MaxHunger,
+		Trace:       u.trace.all(),
+	}
+
+	for _, step := range u.procedure {
+		info.Procedure = append(info.Procedure, step.ToJSON())
+	}
+
+	if u.job != nil {
+		info.Job = u.job.ToJSON()
+		info.JobDuration = u.job.Duration()
+	}
+
+	if u.traversingPath != nil {
+		info.Path = append(info.Path, u.Pos())
+
+		for _, nodeID := range u.traversingPath[u.traversingStep:] {
+			if node, ok := b.Nodes[nodeID]; ok {
+				info.Path = append(info.Path, node.pos)
+			}
+		}
+	}
+
+	return info, nil
+}
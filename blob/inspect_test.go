@@ -0,0 +1,65 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+func TestInspectNodeUnitsHeading(t *testing.T) {
+	b := gridBlob(3)
+	u := b.AddUnit(0)
+	u.procedure = []*ProcedureStep{{stepType: TraverseTo, nodeID: 8}}
+
+	heading := func() bool {
+		info, err := b.InspectNode(8)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return len(info.Units) == 1 && info.Units[0] == u.id
+	}
+
+	if !heading() {
+		t.Fatal("expected the unit about to leave to be listed")
+	}
+
+	b.Update()
+
+	if u.CurrentProcedureStep().stepType != Traverse {
+		t.Fatalf("unit does %s", u.CurrentProcedureStep().stepType)
+	}
+
+	if !heading() {
+		t.Fatal("expected the unit on its way to be listed")
+	}
+
+	info, err := b.InspectNode(4) // not the destination
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.Units) != 0 {
+		t.Fatalf("unexpected units %v heading to a node on the way", info.Units)
+	}
+}
+
+func TestInspectNodeResources(t *testing.T) {
+	b := NewBlob(&BlobJSON{}, overflowConf(nil))
+	id := b.AddNode(pixel.V(0, 0), "farm")
+	b.Nodes[id].AddResource("moss")
+	b.addPile(id, pixel.V(0, 0), "moss")
+
+	info, err := b.InspectNode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Resources["moss"] != 1 || info.Piles["moss"] != 1 {
+		t.Fatalf("resources %v, piles %v", info.Resources, info.Piles)
+	}
+
+	if _, err := b.InspectNode(id + 1); err == nil {
+		t.Fatal("expected an error for a missing node")
+	}
+}
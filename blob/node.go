@@ -3,7 +3,7 @@ package blob
 import (
 	"errors"
 	"math"
-	"math/rand"
+	"sort"
 
 	"private/grow/render"
 
@@ -32,30 +32,72 @@ func (resourceType ResourceType) Render(
 	}
 }
 
+// NodeType names a node configuration, node types are defined entirely by
+// config.
 type NodeType string
 
-const (
-	NodeTypeNone                    NodeType = "none"
-	NodeTypeMossFarm                NodeType = "moss_farm"
-	NodeTypeMossFermentationChamber NodeType = "moss_fermentation_chamber"
-	NodeTypeMushroomFarm            NodeType = "mushroom_farm"
-	NodeTypeStorage                 NodeType = "storage"
-)
-
+// NodeConfig describes a node type. Recipes are run by the node itself, adding
+// ProductionSpeed work per tick, jobs are recipes run by units.
 type NodeConfig struct {
 	Radius           float64              `json:"radius"`
 	ResourceCapacity int                  `json:"resource_capacity"`
 	Consumes         map[ResourceType]int `json:"consumes"`
 	Produces         map[ResourceType]int `json:"produces"`
 	Jobs             []JobType            `json:"jobs"`
+	Recipes          []RecipeType         `json:"recipes"`
+	ProductionSpeed  float64              `json:"production_speed"`
+	Menu             *MenuConfig          `json:"menu"`
 	Graphics         []*render.Primitive  `json:"graphics"`
 }
 
+// MenuConfig places a node or connection type in the editor's menus. Types
+// without it can not be built from the editor.
+type MenuConfig struct {
+	Label string `json:"label"`
+	Order int    `json:"order"`
+}
+
+// MenuNodeTypes returns node types shown in the editor's add node menu, in
+// menu order.
+func (conf *BlobConfig) MenuNodeTypes() []NodeType {
+	return menuTypes(conf.Nodes, func(n *NodeConfig) *MenuConfig {
+		return n.Menu
+	})
+}
+
+// MenuConnectionTypes returns connection types shown in the editor's connect
+// menu, in menu order.
+func (conf *BlobConfig) MenuConnectionTypes() []ConnectionType {
+	return menuTypes(conf.Connections, func(c *ConnectionConfig) *MenuConfig {
+		return c.Menu
+	})
+}
+
+func menuTypes[K ordered, V any](
+	types map[K]V,
+	menu func(V) *MenuConfig,
+) []K {
+	var keys []K
+
+	for _, k := range SortedKeys(types) {
+		if menu(types[k]) != nil {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return menu(types[keys[i]]).Order < menu(types[keys[j]]).Order
+	})
+
+	return keys
+}
+
 type Node struct {
 	id                 int
 	pos                pixel.Vec
 	nodeType           NodeType
 	resources          map[ResourceType][]pixel.Vec
+	recipe             RecipeType
 	productionProgress float64
 	conf               *NodeConfig
 	blob               *Blob
@@ -66,6 +108,7 @@ type NodeJSON struct {
 	Pos                pixel.Vec                    `json:"pos"`
 	NodeType           NodeType                     `json:"node_type"`
 	Resources          map[ResourceType][]pixel.Vec `json:"resources"`
+	Recipe             RecipeType                   `json:"recipe"`
 	ProductionProgress float64                      `json:"production_progress"`
 }
 
@@ -75,6 +118,7 @@ func NewNode(nj *NodeJSON, b *Blob, conf *BlobConfig) *Node {
 		pos:                nj.Pos,
 		nodeType:           nj.NodeType,
 		resources:          nj.Resources,
+		recipe:             nj.Recipe,
 		productionProgress: nj.ProductionProgress,
 	}
 
@@ -94,6 +138,7 @@ func (n *Node) ToJSON() *NodeJSON {
 		Pos:                n.pos,
 		NodeType:           n.nodeType,
 		Resources:          n.resources,
+		Recipe:             n.recipe,
 		ProductionProgress: n.productionProgress,
 	}
 
@@ -104,22 +149,40 @@ func (n *Node) Jobs() []*Job {
 	jobs := make([]*Job, 0, len(n.conf.Jobs))
 
 	for _, jobType := range n.conf.Jobs {
-		jobs = append(jobs, NewJob(
-			&JobJSON{
-				ID:      n.blob.jobsIdentifier,
-				NodeID:  n.id,
-				JobType: jobType,
-			},
-			n.blob.conf,
-			n.blob,
-		))
-
-		n.blob.jobsIdentifier++
+		jobs = append(jobs, n.newJob(jobType))
 	}
 
 	return jobs
 }
 
+func (n *Node) newJob(jobType JobType) *Job {
+	job := NewJob(
+		&JobJSON{
+			ID:      n.blob.jobsIdentifier,
+			NodeID:  n.id,
+			JobType: jobType,
+		},
+		n.blob.conf,
+		n.blob,
+	)
+
+	n.blob.jobsIdentifier++
+
+	return job
+}
+
+func (n *Node) Pos() pixel.Vec {
+	return n.pos
+}
+
+func (n *Node) NodeType() NodeType {
+	return n.nodeType
+}
+
+func (n *Node) Radius() float64 {
+	return n.conf.Radius
+}
+
 func (n *Node) Render(rend *render.Renderer) {
 	rend.Primitives(n.pos, n.conf.Graphics...)
 
@@ -130,22 +193,36 @@ func (n *Node) Render(rend *render.Renderer) {
 	// rend.Text(n.pos, color.RGBA{255, 0, 0, 255}, fmt.Sprintf("%d", n.id), 1)
 }
 
+// Update runs the node's own recipes. A recipe is picked once its inputs are
+// in stock, its progress stalls while they are missing.
 func (n *Node) Update() {
-	switch n.nodeType {
-	case NodeTypeMossFermentationChamber:
-		n.productionProgress += 0.1 // TODO: config
-		if n.productionProgress > 40 {
-			n.productionProgress = 0
-			n.TakeResource(ResourceTypeMoss)
+	if n.recipe == "" {
+		n.recipe = n.nextRecipe()
+		if n.recipe == "" {
+			return
 		}
 	}
+
+	if !n.CanRunRecipe(n.recipe) {
+		return
+	}
+
+	n.productionProgress += n.conf.ProductionSpeed
+	if n.productionProgress < n.blob.conf.Recipes[n.recipe].Duration {
+		return
+	}
+
+	n.RunRecipe(n.recipe)
+
+	n.productionProgress = 0
+	n.recipe = ""
 }
 
 func (n *Node) RandPosInNode() pixel.Vec {
 	return n.pos.Add(
 		pixel.V(n.conf.Radius, 0).
-			Scaled(rand.Float64() * 0.9).
-			Rotated(rand.Float64() * 2 * math.Pi),
+			Scaled(n.blob.rand.Float64() * 0.9).
+			Rotated(n.blob.rand.Float64() * 2 * math.Pi),
 	)
 }
 
@@ -175,8 +252,8 @@ func (n *Node) TakeResource(resourceType ResourceType) error {
 func (n *Node) Consumes() []ResourceType {
 	consumes := make([]ResourceType, 0, len(n.conf.Consumes))
 
-	for _, c := range n.conf.Consumes {
-		consumes = append(consumes, ResourceType(c))
+	for resourceType := range n.conf.Consumes {
+		consumes = append(consumes, resourceType)
 	}
 
 	return consumes
@@ -200,6 +277,40 @@ func (n *Node) AllResourcesCount() int {
 	return count
 }
 
+// fitResources drops stock over the node's capacity, resources the node
+// neither consumes nor produces go first. Resources lying outside of the node
+// are moved into it.
+func (n *Node) fitResources() {
+	var unused, used []ResourceType
+
+	for _, res := range SortedKeys(n.resources) {
+		_, consumes := n.conf.Consumes[res]
+		_, produces := n.conf.Produces[res]
+
+		if consumes || produces {
+			used = append(used, res)
+		} else {
+			unused = append(unused, res)
+		}
+	}
+
+	for _, res := range append(unused, used...) {
+		for n.AvailableCapacity() < 0 && len(n.resources[res]) > 0 {
+			n.resources[res] = n.resources[res][1:]
+		}
+	}
+
+	for _, res := range SortedKeys(n.resources) {
+		positions := n.resources[res]
+
+		for i, pos := range positions {
+			if pos.Sub(n.pos).Len() > n.conf.Radius {
+				positions[i] = n.RandPosInNode()
+			}
+		}
+	}
+}
+
 func (n *Node) RemoveResources() {
 	n.resources = make(map[ResourceType][]pixel.Vec)
 }
@@ -232,12 +343,11 @@ type JobType string
 
 const JobTypeGrowMoss JobType = "grow_moss"
 
+// JobConfig is work done by units at a node. A unit doing the job adds
+// ProductionSpeed to the progress of the recipe every tick.
 type JobConfig struct {
-	ProducedResource ResourceType `json:"produced_resource"`
-	ProductionSpeed  float64      `json:"production_speed"`
-
-	// latter this will allow to add support for multiple produced resources,
-	// required resources, ...
+	Recipe          RecipeType `json:"recipe"`
+	ProductionSpeed float64    `json:"production_speed"`
 }
 
 type Job struct {
@@ -286,10 +396,19 @@ func (j *Job) ToJSON() *JobJSON {
 	return jj
 }
 
+func (j *Job) halted() *JobHalted {
+	return &JobHalted{JobID: j.id, NodeID: j.nodeID, JobType: j.jobType}
+}
+
 func (j *Job) Complete() error {
-	return j.blob.Nodes[j.nodeID].AddResource(j.conf.ProducedResource)
+	return j.blob.Nodes[j.nodeID].RunRecipe(j.conf.Recipe)
 }
 
 func (j *Job) CanDo() bool {
-	return j.blob.Nodes[j.nodeID].AvailableCapacity() > 0
+	return j.blob.Nodes[j.nodeID].CanRunRecipe(j.conf.Recipe)
+}
+
+// Duration returns the amount of work needed to complete the job.
+func (j *Job) Duration() float64 {
+	return j.blob.conf.Recipes[j.conf.Recipe].Duration
 }
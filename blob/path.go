@@ -0,0 +1,318 @@
+package blob
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+)
+
+// pathKey identifies a path search. Unlike ConnectionIDs the order of the
+// nodes matters.
+type pathKey struct {
+	From int
+	To   int
+	Cost EdgeCost
+}
+
+// cachedPath is a path search result, searches that found no path are
+// cached too.
+type cachedPath struct {
+	path  []int
+	found bool
+}
+
+// invalidatePaths clears the path caches, call it on every change of the
+// graph.
+func (b *Blob) invalidatePaths() {
+	b.pathCache = make(map[pathKey]cachedPath)
+	b.expirePaths()
+}
+
+// expirePaths drops paths weighed by costs other than length, they change as
+// units move and are only cached for a tick.
+func (b *Blob) expirePaths() {
+	if len(b.tickPaths) > 0 {
+		b.tickPaths = make(map[pathKey]cachedPath)
+	}
+}
+
+// Dijkstra returns the shortest path from start to target node, excluding
+// the start node. Results are cached until the graph changes.
+func (b *Blob) Dijkstra(startNodeID, targetNodeID int) ([]int, error) {
+	if startNodeID == targetNodeID {
+		return nil, nil
+	}
+
+	key := pathKey{From: startNodeID, To: targetNodeID, Cost: LengthCost{}}
+
+	cached, ok := b.pathCache[key]
+	if !ok {
+		cached.path, cached.found = b.dijkstra(startNodeID, targetNodeID)
+		b.pathCache[key] = cached
+	}
+
+	if !cached.found {
+		return nil, errors.New("no path found")
+	}
+
+	return cached.path, nil
+}
+
+func (b *Blob) dijkstra(startNodeID, targetNodeID int) ([]int, bool) {
+	dist := map[int]float64{startNodeID: 0}
+	prev := make(map[int]int)
+	done := make(map[int]bool)
+
+	queue := &pathQueue{{nodeID: startNodeID}}
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(pathItem)
+		if done[item.nodeID] {
+			continue
+		}
+
+		if item.nodeID == targetNodeID {
+			break
+		}
+
+		done[item.nodeID] = true
+
+		for _, conn := range b.adjacency[item.nodeID] {
+			neighborID := conn.Nodes.Opposite(item.nodeID)
+			if done[neighborID] {
+				continue
+			}
+
+			alt := item.dist + conn.Length
+
+			d, ok := dist[neighborID]
+			if ok && d <= alt {
+				continue
+			}
+
+			dist[neighborID] = alt
+			prev[neighborID] = item.nodeID
+
+			heap.Push(queue, pathItem{nodeID: neighborID, dist: alt})
+		}
+	}
+
+	if _, ok := dist[targetNodeID]; !ok {
+		return nil, false
+	}
+
+	path := []int{}
+
+	for node := targetNodeID; node != startNodeID; node = prev[node] {
+		path = append(path, node)
+	}
+
+	return ReverseSlice(path), true
+}
+
+type pathItem struct {
+	nodeID int
+	dist   float64
+}
+
+// pathQueue is a min heap of nodes ordered by distance, ties go to the lowest
+// node id to keep searches deterministic.
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int {
+	return len(q)
+}
+
+func (q pathQueue) Less(i, j int) bool {
+	if q[i].dist == q[j].dist {
+		return q[i].nodeID < q[j].nodeID
+	}
+
+	return q[i].dist < q[j].dist
+}
+
+func (q pathQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *pathQueue) Push(x any) {
+	*q = append(*q, x.(pathItem))
+}
+
+func (q *pathQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return item
+}
+
+// EdgeCost weighs connections for path finding. Costs are part of path cache
+// keys, so they have to be comparable, such as pointers.
+type EdgeCost interface {
+	// Cost returns the cost of traversing the connection, math.Inf(1) if it
+	// can not be traversed.
+	Cost(conn *Connection) float64
+
+	// MinCostPerLength is a lower bound of Cost divided by the connection's
+	// length. It scales the A* heuristic so that it never overestimates.
+	MinCostPerLength() float64
+}
+
+// LengthCost weighs connections by their length.
+type LengthCost struct{}
+
+func (LengthCost) Cost(conn *Connection) float64 {
+	return conn.Length
+}
+
+func (LengthCost) MinCostPerLength() float64 {
+	return 1
+}
+
+// TrafficCost weighs connections by the time it takes to traverse them,
+// increased by Weight for every unit currently traversing them.
+type TrafficCost struct {
+	Blob   *Blob
+	Weight float64
+}
+
+func (c *TrafficCost) Cost(conn *Connection) float64 {
+	speed := c.Blob.connectionConfig(conn.Type).SpeedMultiplier
+	traffic := float64(c.Blob.Traffic(conn.Nodes))
+
+	return conn.Length / speed * (1 + c.Weight*traffic)
+}
+
+func (c *TrafficCost) MinCostPerLength() float64 {
+	maxSpeed := defaultConnectionConfig.SpeedMultiplier
+
+	for _, conf := range c.Blob.conf.Connections {
+		maxSpeed = math.Max(maxSpeed, conf.SpeedMultiplier)
+	}
+
+	return 1 / maxSpeed
+}
+
+type PathfindingConfig struct {
+	TrafficWeight float64 `json:"traffic_weight"`
+}
+
+// SetEdgeCost sets the edge cost used for routing units.
+func (b *Blob) SetEdgeCost(cost EdgeCost) {
+	b.edgeCost = cost
+}
+
+// FindPath returns the path units take from start to target node, excluding
+// the start node, weighed by the blob's edge cost. Paths by length are cached
+// until the graph changes, others for the rest of the tick.
+func (b *Blob) FindPath(startNodeID, targetNodeID int) ([]int, error) {
+	if _, ok := b.edgeCost.(LengthCost); ok {
+		return b.Dijkstra(startNodeID, targetNodeID)
+	}
+
+	if startNodeID == targetNodeID {
+		return nil, nil
+	}
+
+	key := pathKey{From: startNodeID, To: targetNodeID, Cost: b.edgeCost}
+
+	cached, ok := b.tickPaths[key]
+	if !ok {
+		path, err := b.AStar(startNodeID, targetNodeID, b.edgeCost)
+		cached = cachedPath{path: path, found: err == nil}
+		b.tickPaths[key] = cached
+	}
+
+	if !cached.found {
+		return nil, errors.New("no path found")
+	}
+
+	return cached.path, nil
+}
+
+// AStar returns the cheapest path from start to target node by cost, excluding
+// the start node. The straight line distance between nodes is used as the
+// heuristic.
+func (b *Blob) AStar(
+	startNodeID, targetNodeID int,
+	cost EdgeCost,
+) ([]int, error) {
+	if startNodeID == targetNodeID {
+		return nil, nil
+	}
+
+	start := b.Nodes[startNodeID]
+	target := b.Nodes[targetNodeID]
+
+	if start == nil || target == nil {
+		return nil, errors.New("node not found")
+	}
+
+	heuristic := func(n *Node) float64 {
+		return n.pos.Sub(target.pos).Len() * cost.MinCostPerLength()
+	}
+
+	dist := map[int]float64{startNodeID: 0}
+	prev := make(map[int]int)
+	done := make(map[int]bool)
+
+	queue := &pathQueue{{nodeID: startNodeID, dist: heuristic(start)}}
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(pathItem)
+		if done[item.nodeID] {
+			continue
+		}
+
+		if item.nodeID == targetNodeID {
+			break
+		}
+
+		done[item.nodeID] = true
+
+		for _, conn := range b.adjacency[item.nodeID] {
+			neighborID := conn.Nodes.Opposite(item.nodeID)
+			if done[neighborID] {
+				continue
+			}
+
+			c := cost.Cost(conn)
+			if math.IsInf(c, 1) {
+				continue
+			}
+
+			alt := dist[item.nodeID] + c
+
+			d, ok := dist[neighborID]
+			if ok && d <= alt {
+				continue
+			}
+
+			dist[neighborID] = alt
+			prev[neighborID] = item.nodeID
+
+			heap.Push(queue, pathItem{
+				nodeID: neighborID,
+				dist:   alt + heuristic(b.Nodes[neighborID]),
+			})
+		}
+	}
+
+	if _, ok := dist[targetNodeID]; !ok {
+		return nil, errors.New("no path found")
+	}
+
+	path := []int{}
+
+	for node := targetNodeID; node != startNodeID; node = prev[node] {
+		path = append(path, node)
+	}
+
+	return ReverseSlice(path), nil
+}
+
+// Traffic returns the number of units currently traversing the connection.
+func (b *Blob) Traffic(connIDs ConnectionIDs) int {
+	return b.traffic[connIDs]
+}
@@ -0,0 +1,232 @@
+package blob
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+var testConf = &BlobConfig{
+	Nodes: map[NodeType]*NodeConfig{
+		"none": {Radius: 5},
+	},
+	Unit: &UnitConfig{},
+}
+
+// gridBlob returns a blob of size*size nodes, each connected to its right and
+// upper neighbour.
+func gridBlob(size int) *Blob {
+	b := NewBlob(&BlobJSON{}, testConf)
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			b.AddNode(pixel.V(float64(x*50), float64(y*50)), "none")
+		}
+	}
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			id := y*size + x
+
+			if x+1 < size {
+				b.Connect(id, id+1, "")
+			}
+
+			if y+1 < size {
+				b.Connect(id, id+size, "")
+			}
+		}
+	}
+
+	return b
+}
+
+func TestDijkstraCacheInvalidation(t *testing.T) {
+	b := gridBlob(3)
+	far := b.AddNode(pixel.V(500, 500), "none")
+
+	_, err := b.Dijkstra(0, far)
+	if err == nil {
+		t.Fatal("expected no path to unconnected node")
+	}
+
+	b.Connect(8, far, "")
+
+	path, err := b.Dijkstra(0, far)
+	if err != nil {
+		t.Fatalf("expected path after connecting: %v", err)
+	}
+
+	if len(path) != 5 || path[len(path)-1] != far {
+		t.Errorf("unexpected path %v", path)
+	}
+
+	b.Connect(0, far, "")
+
+	path, err = b.Dijkstra(0, far)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(path) != 1 {
+		t.Errorf("expected direct path after connecting, got %v", path)
+	}
+}
+
+// 71*71 = 5041 nodes
+const benchGridSize = 71
+
+func BenchmarkDijkstra(bench *testing.B) {
+	b := gridBlob(benchGridSize)
+	target := benchGridSize*benchGridSize - 1
+
+	bench.ResetTimer()
+
+	for i := 0; i < bench.N; i++ {
+		b.invalidatePaths()
+
+		_, err := b.Dijkstra(0, target)
+		if err != nil {
+			bench.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDijkstraCached(bench *testing.B) {
+	b := gridBlob(benchGridSize)
+	target := benchGridSize*benchGridSize - 1
+
+	bench.ResetTimer()
+
+	for i := 0; i < bench.N; i++ {
+		_, err := b.Dijkstra(0, target)
+		if err != nil {
+			bench.Fatal(err)
+		}
+	}
+}
+
+// blockedCost blocks a single connection.
+type blockedCost struct {
+	blocked ConnectionIDs
+}
+
+func (c blockedCost) Cost(conn *Connection) float64 {
+	if conn.Nodes == c.blocked {
+		return math.Inf(1)
+	}
+
+	return conn.Length
+}
+
+func (c blockedCost) MinCostPerLength() float64 {
+	return 1
+}
+
+func TestAStar(t *testing.T) {
+	b := gridBlob(3)
+
+	path, err := b.AStar(0, 2, LengthCost{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(path) != 2 || path[0] != 1 || path[1] != 2 {
+		t.Errorf("expected path [1 2], got %v", path)
+	}
+
+	path, err = b.AStar(0, 2, blockedCost{NewConnectionIDs(1, 2)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(path) != 4 || path[len(path)-1] != 2 {
+		t.Errorf("expected path around blocked edge, got %v", path)
+	}
+
+	path, err = b.AStar(0, 2, blockedCost{NewConnectionIDs(0, 1)})
+	if err != nil {
+		t.Fatalf("expected path through node 3: %v", err)
+	}
+
+	if len(path) != 4 || path[0] != 3 || path[len(path)-1] != 2 {
+		t.Errorf("expected path through node 3, got %v", path)
+	}
+}
+
+func TestFindPathCachesTrafficPathsForATick(t *testing.T) {
+	b := gridBlob(3)
+	cost := &TrafficCost{Blob: b, Weight: 10}
+	b.SetEdgeCost(cost)
+
+	path, err := b.FindPath(0, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(path) != 2 || path[0] != 1 {
+		t.Fatalf("expected path [1 2], got %v", path)
+	}
+
+	key := pathKey{From: 0, To: 2, Cost: cost}
+	if _, ok := b.tickPaths[key]; !ok {
+		t.Fatal("expected the path to be cached")
+	}
+
+	if _, ok := b.pathCache[key]; ok {
+		t.Fatal("expected the path to be kept out of the length cache")
+	}
+
+	b.traffic[NewConnectionIDs(0, 1)] = 5
+
+	path, err = b.FindPath(0, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path[0] != 1 {
+		t.Fatalf("expected the cached path during the tick, got %v", path)
+	}
+
+	b.Update()
+
+	if _, ok := b.tickPaths[key]; ok {
+		t.Fatal("expected the path to expire with the tick")
+	}
+
+	path, err = b.FindPath(0, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path[0] == 1 {
+		t.Fatalf("expected a path around the traffic, got %v", path)
+	}
+
+	if _, err := b.Dijkstra(0, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b.Update()
+
+	lengthKey := pathKey{From: 0, To: 2, Cost: LengthCost{}}
+	if _, ok := b.pathCache[lengthKey]; !ok {
+		t.Fatal("expected the path by length to stay cached")
+	}
+}
+
+func BenchmarkAStar(bench *testing.B) {
+	b := gridBlob(benchGridSize)
+	target := benchGridSize*benchGridSize - 1
+	cost := &TrafficCost{Blob: b, Weight: 0.2}
+
+	bench.ResetTimer()
+
+	for i := 0; i < bench.N; i++ {
+		_, err := b.AStar(0, target, cost)
+		if err != nil {
+			bench.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package blob
+
+import (
+	"image/color"
+
+	"private/grow/render"
+
+	"github.com/faiface/pixel"
+)
+
+// OverflowPolicy is what a unit does with a resource it can't deliver,
+// because no consumer has room or none can be reached.
+type OverflowPolicy string
+
+const (
+	// OverflowDrop puts the resource into the unit's node if the node
+	// consumes or produces it and has room, it is piled otherwise.
+	OverflowDrop OverflowPolicy = "drop"
+	// OverflowCarry keeps carrying the resource, the unit waits and looks for
+	// a consumer again. The wait doubles with every retry, after MaxRetries
+	// the resource is dropped.
+	OverflowCarry OverflowPolicy = "carry"
+	// OverflowPile leaves the resource on the ground at the unit's position.
+	OverflowPile OverflowPolicy = "pile"
+)
+
+// maxOverflowRetries bounds the doubling retry wait.
+const maxOverflowRetries = 16
+
+func (p OverflowPolicy) Valid() bool {
+	switch p {
+	case OverflowDrop, OverflowCarry, OverflowPile:
+		return true
+	}
+
+	return false
+}
+
+type OverflowConfig struct {
+	Policy     OverflowPolicy `json:"policy"`
+	RetryTicks int            `json:"retry_ticks"` // first wait of carry
+	MaxRetries int            `json:"max_retries"`
+}
+
+// defaultOverflow is used when the unit config has no overflow section.
+var defaultOverflow = &OverflowConfig{Policy: OverflowDrop}
+
+// Pile is a resource lying on the ground, left by a unit that couldn't
+// deliver it. It belongs to the node it was left at without taking up the
+// node's capacity, carriers collect piles before producers' stock.
+type Pile struct {
+	id       int
+	nodeID   int
+	pos      pixel.Vec
+	resource ResourceType
+}
+
+type PileJSON struct {
+	ID       int          `json:"id"`
+	NodeID   int          `json:"node"`
+	Pos      pixel.Vec    `json:"pos"`
+	Resource ResourceType `json:"resource"`
+}
+
+func NewPile(pj *PileJSON) *Pile {
+	return &Pile{
+		id:       pj.ID,
+		nodeID:   pj.NodeID,
+		pos:      pj.Pos,
+		resource: pj.Resource,
+	}
+}
+
+func (p *Pile) ToJSON() *PileJSON {
+	return &PileJSON{
+		ID:       p.id,
+		NodeID:   p.nodeID,
+		Pos:      p.pos,
+		Resource: p.resource,
+	}
+}
+
+func (p *Pile) Render(rend *render.Renderer, conf *BlobConfig) {
+	rend.Circle(p.pos, color.RGBA{90, 70, 40, 255}, 7, 2)
+	p.resource.Render(rend, conf, p.pos)
+}
+
+func (b *Blob) addPile(nodeID int, pos pixel.Vec, res ResourceType) int {
+	id := b.pilesIdentifier
+	b.pilesIdentifier++
+
+	b.piles[id] = &Pile{id: id, nodeID: nodeID, pos: pos, resource: res}
+
+	return id
+}
+
+// collectablePile returns the oldest pile no unit is collecting yet whose
+// resource has a consumer with room.
+func (b *Blob) collectablePile() (*Pile, bool) {
+	if len(b.piles) == 0 {
+		return nil, false
+	}
+
+	collecting := make(map[int]bool)
+
+	for _, u := range b.Units {
+		for _, step := range u.procedure {
+			if step.stepType == PickUpPile {
+				collecting[step.pileID] = true
+			}
+		}
+	}
+
+	for _, id := range SortedKeys(b.piles) {
+		pile := b.piles[id]
+
+		if collecting[id] {
+			continue
+		}
+
+		if _, err := b.GetConsumerNodeID(pile.resource); err != nil {
+			continue
+		}
+
+		return pile, true
+	}
+
+	return nil, false
+}
+
+// movePiles moves the piles of a removed node to the closest remaining node,
+// they are lost if there is none.
+func (b *Blob) movePiles(removed *Node) {
+	for _, id := range SortedKeys(b.piles) {
+		pile := b.piles[id]
+		if pile.nodeID != removed.id {
+			continue
+		}
+
+		closestID, err := b.GetClosestNode(removed.pos)
+		if err != nil {
+			b.emit(&ResourceLost{
+				NodeID:   pile.nodeID,
+				PileID:   id,
+				Resource: pile.resource,
+				Reason:   "node removed",
+			})
+
+			delete(b.piles, id)
+
+			continue
+		}
+
+		pile.nodeID = closestID
+		pile.pos = b.Nodes[closestID].RandPosInNode()
+	}
+}
+
+func (u *Unit) overflowConfig() *OverflowConfig {
+	if u.conf.Overflow == nil {
+		return defaultOverflow
+	}
+
+	return u.conf.Overflow
+}
+
+// overflow deals with a carried resource no consumer can take, following the
+// overflow policy. Unless it keeps carrying, the unit wanders off.
+func (u *Unit) overflow(reason string) {
+	conf := u.overflowConfig()
+
+	switch conf.Policy {
+	case OverflowCarry:
+		if u.retries < conf.MaxRetries {
+			u.procedure = []*ProcedureStep{
+				{
+					stepType: AwaitConsumer,
+					ticks:    conf.RetryTicks << u.retries,
+				},
+				{
+					stepType: FindConsumer,
+				},
+				{
+					stepType: DropResource,
+				},
+			}
+			u.retries++
+
+			return
+		}
+
+		u.dropResource(reason)
+	case OverflowPile:
+		u.pileResource(reason)
+	default:
+		u.dropResource(reason)
+	}
+
+	u.retries = 0
+	u.ClearProcedure()
+	u.SetCurrentProcedureStep(Wander)
+}
+
+// dropResource puts the carried resource into the node the unit is on, to be
+// carried on from there. Resources the node has no use for or no room for
+// are piled, as are resources of units on a connection.
+func (u *Unit) dropResource(reason string) {
+	if u.resource == ResourceTypeNone {
+		return
+	}
+
+	node := u.blob.Nodes[u.nodeID]
+	_, consumes := node.conf.Consumes[u.resource]
+	_, produces := node.conf.Produces[u.resource]
+
+	if u.traversingConnection == nil && (consumes || produces) &&
+		node.AddResource(u.resource) == nil {
+		u.blob.emit(&ResourceDropped{
+			UnitID:   u.id,
+			NodeID:   u.nodeID,
+			Resource: u.resource,
+		})
+
+		u.resource = ResourceTypeNone
+
+		return
+	}
+
+	u.pileResource(reason)
+}
+
+// pileResource leaves the carried resource on the ground at the unit's
+// position.
+func (u *Unit) pileResource(reason string) {
+	if u.resource == ResourceTypeNone {
+		return
+	}
+
+	id := u.blob.addPile(u.nodeID, u.Pos(), u.resource)
+
+	u.blob.emit(&ResourcePiled{
+		UnitID:   u.id,
+		NodeID:   u.nodeID,
+		PileID:   id,
+		Resource: u.resource,
+		Reason:   reason,
+	})
+
+	u.resource = ResourceTypeNone
+}
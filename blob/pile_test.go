@@ -0,0 +1,477 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+func overflowConf(overflow *OverflowConfig) *BlobConfig {
+	return &BlobConfig{
+		Nodes: map[NodeType]*NodeConfig{
+			"none": {Radius: 5},
+			"farm": {
+				Radius:           5,
+				ResourceCapacity: 2,
+				Produces:         map[ResourceType]int{"moss": 0},
+			},
+			"store": {
+				Radius:           5,
+				ResourceCapacity: 1,
+				Consumes:         map[ResourceType]int{"moss": 0},
+			},
+		},
+		Resources: map[ResourceType]*ResourceConfig{"moss": {}},
+		Unit: &UnitConfig{
+			TraversalSpeed: 5,
+			MaxHunger:      1e9,
+			Overflow:       overflow,
+		},
+	}
+}
+
+// carrying adds a unit on the node carrying moss, about to look for a
+// consumer.
+func carrying(b *Blob, nodeID int) *Unit {
+	u := b.AddUnit(nodeID)
+	u.resource = "moss"
+	u.deliver()
+
+	return u
+}
+
+// countMoss returns the moss in stock, on piles and carried.
+func countMoss(b *Blob) int {
+	count := 0
+
+	for _, node := range b.Nodes {
+		count += node.ResourceCount("moss")
+	}
+
+	for _, pile := range b.piles {
+		if pile.resource == "moss" {
+			count++
+		}
+	}
+
+	for _, u := range b.Units {
+		if u.resource == "moss" {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestResetKeepsCarriedResource(t *testing.T) {
+	b := NewBlob(&BlobJSON{}, overflowConf(nil))
+	farm := b.AddNode(pixel.V(0, 0), "farm")
+	store := b.AddNode(pixel.V(100, 0), "store")
+	b.Connect(farm, store, "")
+	b.Nodes[farm].AddResource("moss")
+
+	u := carrying(b, farm)
+
+	for i := 0; i < 5; i++ {
+		b.Update()
+	}
+
+	if u.traversingConnection == nil {
+		t.Fatal("expected the unit to be on its way to the store")
+	}
+
+	err := b.RemoveNode(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 200; i++ {
+		b.Update()
+
+		if count := countMoss(b); count != 2 {
+			t.Fatalf("tick %d: %d moss, expected 2", b.Tick(), count)
+		}
+	}
+}
+
+// events returns the events of the blob from now on.
+func events(b *Blob) *[]Event {
+	var all []Event
+
+	b.Subscribe(func(_ int, event Event) {
+		all = append(all, event)
+	})
+
+	return &all
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow *OverflowConfig
+		nodeType NodeType
+		stocked  bool // dropped into the node
+		piled    bool
+	}{
+		{"default drops", nil, "farm", true, false},
+		{"drop", &OverflowConfig{Policy: OverflowDrop}, "farm", true, false},
+		{
+			"drop on a node without use",
+			&OverflowConfig{Policy: OverflowDrop},
+			"none",
+			false,
+			true,
+		},
+		{"pile", &OverflowConfig{Policy: OverflowPile}, "farm", false, true},
+		{
+			"carry without retries",
+			&OverflowConfig{Policy: OverflowCarry, RetryTicks: 5},
+			"farm",
+			true,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBlob(&BlobJSON{}, overflowConf(tt.overflow))
+			id := b.AddNode(pixel.V(0, 0), tt.nodeType)
+			b.AddNode(pixel.V(100, 0), "store") // not connected
+
+			u := carrying(b, id)
+			all := events(b)
+
+			b.Update()
+
+			if u.resource != ResourceTypeNone {
+				t.Fatalf("unit still carries %q", u.resource)
+			}
+
+			stocked := b.Nodes[id].ResourceCount("moss") == 1
+			if stocked != tt.stocked {
+				t.Errorf("stocked %v, expected %v", stocked, tt.stocked)
+			}
+
+			if got := len(b.piles) == 1; got != tt.piled {
+				t.Errorf("piled %v, expected %v", got, tt.piled)
+			}
+
+			if len(*all) != 1 {
+				t.Fatalf("expected one event, got %v", *all)
+			}
+
+			switch e := (*all)[0].(type) {
+			case *ResourceDropped:
+				if !tt.stocked {
+					t.Errorf("unexpected drop %+v", e)
+				}
+			case *ResourcePiled:
+				if !tt.piled || e.Reason != "no path to consumer" {
+					t.Errorf("unexpected pile %+v", e)
+				}
+			default:
+				t.Errorf("unexpected event %+v", e)
+			}
+
+			if u.CurrentProcedureStep().stepType != Wander {
+				t.Errorf(
+					"unit does %s, expected to wander",
+					u.CurrentProcedureStep().stepType,
+				)
+			}
+		})
+	}
+}
+
+func TestOverflowCarryBackoff(t *testing.T) {
+	b := NewBlob(&BlobJSON{}, overflowConf(&OverflowConfig{
+		Policy:     OverflowCarry,
+		RetryTicks: 3,
+		MaxRetries: 3,
+	}))
+	id := b.AddNode(pixel.V(0, 0), "none")
+	u := carrying(b, id)
+
+	var waits []int
+
+	waiting := false
+
+	for i := 0; i < 100 && u.resource != ResourceTypeNone; i++ {
+		b.Update()
+
+		step := u.CurrentProcedureStep()
+		if step.stepType == AwaitConsumer && !waiting {
+			waits = append(waits, step.ticks)
+		}
+
+		waiting = step.stepType == AwaitConsumer
+	}
+
+	expected := []int{3, 6, 12}
+
+	if len(waits) != len(expected) {
+		t.Fatalf("waited %v, expected %v", waits, expected)
+	}
+
+	for i := range expected {
+		if waits[i] != expected[i] {
+			t.Fatalf("waited %v, expected %v", waits, expected)
+		}
+	}
+
+	if u.resource != ResourceTypeNone || len(b.piles) != 1 {
+		t.Fatal("expected the resource to be piled after the last retry")
+	}
+
+	if u.retries != 0 {
+		t.Errorf("retries %d, expected them to be reset", u.retries)
+	}
+}
+
+func TestOverflowCarryDelivers(t *testing.T) {
+	b := NewBlob(&BlobJSON{}, overflowConf(&OverflowConfig{
+		Policy:     OverflowCarry,
+		RetryTicks: 3,
+		MaxRetries: 3,
+	}))
+	id := b.AddNode(pixel.V(0, 0), "none")
+	u := carrying(b, id)
+
+	b.Update()
+
+	if u.CurrentProcedureStep().stepType != AwaitConsumer {
+		t.Fatalf("unit does %s", u.CurrentProcedureStep().stepType)
+	}
+
+	store := b.AddNode(pixel.V(20, 0), "store")
+	b.Connect(id, store, "")
+
+	for i := 0; i < 50 && u.resource != ResourceTypeNone; i++ {
+		b.Update()
+	}
+
+	if b.Nodes[store].ResourceCount("moss") != 1 {
+		t.Fatal("expected the carried resource to be delivered")
+	}
+
+	if u.retries != 0 {
+		t.Errorf("retries %d, expected them to be reset", u.retries)
+	}
+}
+
+func TestCollectablePile(t *testing.T) {
+	b := NewBlob(&BlobJSON{}, overflowConf(nil))
+	id := b.AddNode(pixel.V(0, 0), "none")
+	pileID := b.addPile(id, pixel.V(0, 0), "moss")
+
+	if _, ok := b.collectablePile(); ok {
+		t.Fatal("expected no collectable pile without a consumer")
+	}
+
+	store := b.AddNode(pixel.V(20, 0), "store")
+	b.Connect(id, store, "")
+
+	pile, ok := b.collectablePile()
+	if !ok || pile.id != pileID {
+		t.Fatal("expected the pile to be collectable")
+	}
+
+	u := b.AddUnit(id)
+	u.procedure = []*ProcedureStep{{stepType: StartCarry}}
+	b.Update()
+
+	if !u.heading(id) {
+		t.Fatal("expected the unit to go for the pile")
+	}
+
+	if _, ok := b.collectablePile(); ok {
+		t.Fatal("expected the pile to be taken by the unit")
+	}
+
+	all := events(b)
+
+	for i := 0; i < 50 && b.Nodes[store].ResourceCount("moss") == 0; i++ {
+		b.Update()
+	}
+
+	if len(b.piles) != 0 || b.Nodes[store].ResourceCount("moss") != 1 {
+		t.Fatal("expected the pile to be carried to the store")
+	}
+
+	collected := false
+
+	for _, event := range *all {
+		if e, ok := event.(*PileCollected); ok && e.PileID == pileID {
+			collected = true
+		}
+	}
+
+	if !collected {
+		t.Error("expected a pile collected event")
+	}
+}
+
+func TestMovePiles(t *testing.T) {
+	b := NewBlob(&BlobJSON{}, overflowConf(nil))
+	first := b.AddNode(pixel.V(0, 0), "none")
+	last := b.AddNode(pixel.V(50, 0), "none")
+	pileID := b.addPile(first, pixel.V(0, 0), "moss")
+
+	all := events(b)
+
+	err := b.RemoveNode(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.piles[pileID] == nil || b.piles[pileID].nodeID != last {
+		t.Fatal("expected the pile to move to the remaining node")
+	}
+
+	err = b.RemoveNode(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.piles) != 0 {
+		t.Fatal("expected the pile to be removed with the last node")
+	}
+
+	if len(*all) != 1 {
+		t.Fatalf("expected one event, got %v", *all)
+	}
+
+	e, ok := (*all)[0].(*ResourceLost)
+	if !ok || e.PileID != pileID || e.NodeID != last {
+		t.Fatalf("unexpected event %+v", (*all)[0])
+	}
+}
+
+func TestValidatePiles(t *testing.T) {
+	conf := overflowConf(nil)
+
+	tests := []struct {
+		name  string
+		piles map[int]*PileJSON
+		paths []string
+		kept  int
+	}{
+		{
+			"valid",
+			map[int]*PileJSON{0: {ID: 0, NodeID: 0, Resource: "moss"}},
+			nil,
+			1,
+		},
+		{"empty", map[int]*PileJSON{0: nil}, []string{"piles.0"}, 0},
+		{
+			"id mismatch",
+			map[int]*PileJSON{0: {ID: 3, NodeID: 0, Resource: "moss"}},
+			[]string{"piles.0.id"},
+			1,
+		},
+		{
+			"missing node",
+			map[int]*PileJSON{0: {ID: 0, NodeID: 7, Resource: "moss"}},
+			[]string{"piles.0.node"},
+			0,
+		},
+		{
+			"unknown resource",
+			map[int]*PileJSON{0: {ID: 0, NodeID: 0, Resource: "gold"}},
+			[]string{"piles.0.resource"},
+			0,
+		},
+		{
+			"identifier",
+			map[int]*PileJSON{4: {ID: 4, NodeID: 0, Resource: "moss"}},
+			[]string{"piles_identifier"},
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBlob(&BlobJSON{}, conf)
+			b.AddNode(pixel.V(0, 0), "none")
+
+			bj := b.ToJSON()
+			bj.Piles = tt.piles
+			bj.PilesIdentifier = 1
+
+			errs := bj.Repair(conf)
+
+			if len(errs) != len(tt.paths) {
+				t.Fatalf("got %v, expected errors at %v", errs, tt.paths)
+			}
+
+			for i, err := range errs {
+				if err.Path != tt.paths[i] {
+					t.Errorf("got %v, expected errors at %v", errs, tt.paths)
+				}
+			}
+
+			if len(bj.Piles) != tt.kept {
+				t.Errorf("kept %d piles, expected %d", len(bj.Piles), tt.kept)
+			}
+
+			if errs := bj.Validate(conf); len(errs) > 0 {
+				t.Errorf("repaired save is invalid: %v", errs)
+			}
+		})
+	}
+}
+
+func TestValidateOverflowConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow *OverflowConfig
+		paths    []string
+	}{
+		{"missing", nil, nil},
+		{"drop", &OverflowConfig{Policy: OverflowDrop}, nil},
+		{
+			"carry",
+			&OverflowConfig{
+				Policy:     OverflowCarry,
+				RetryTicks: 1,
+				MaxRetries: 2,
+			},
+			nil,
+		},
+		{
+			"unknown policy",
+			&OverflowConfig{Policy: "burn"},
+			[]string{"unit.overflow.policy"},
+		},
+		{
+			"carry without wait",
+			&OverflowConfig{Policy: OverflowCarry, MaxRetries: 2},
+			[]string{"unit.overflow.retry_ticks"},
+		},
+		{
+			"too many retries",
+			&OverflowConfig{
+				Policy:     OverflowCarry,
+				RetryTicks: 1,
+				MaxRetries: 17,
+			},
+			[]string{"unit.overflow.max_retries"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := overflowConf(tt.overflow).Validate()
+
+			if len(errs) != len(tt.paths) {
+				t.Fatalf("got %v, expected errors at %v", errs, tt.paths)
+			}
+
+			for i, err := range errs {
+				if err.Path != tt.paths[i] {
+					t.Errorf("got %v, expected errors at %v", errs, tt.paths)
+				}
+			}
+		})
+	}
+}
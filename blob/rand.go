@@ -0,0 +1,40 @@
+package blob
+
+import "math/rand"
+
+// randSource is a splitmix64 rand.Source. Its whole state is a single uint64,
+// which allows to store it in a save and to continue the exact same sequence
+// after loading.
+type randSource struct {
+	state uint64
+}
+
+func newRandSource(seed int64, state uint64) *randSource {
+	s := &randSource{state: state}
+
+	if state == 0 {
+		s.Seed(seed)
+	}
+
+	return s
+}
+
+func (s *randSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *randSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
+
+func (s *randSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+var _ rand.Source64 = (*randSource)(nil)
@@ -0,0 +1,66 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func saveJSON(t *testing.T, b *Blob) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(b.ToJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestSameSeedSameRun(t *testing.T) {
+	const ticks = 2000
+
+	newBlob := func() *Blob {
+		b := lineBlob(6)
+
+		_, err := b.Connect(0, 5, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return b
+	}
+
+	// the last blob is saved and loaded halfway
+	blobs := []*Blob{newBlob(), newBlob(), newBlob()}
+	start := blobs[0].randSource.state
+
+	for i := 0; i < ticks; i++ {
+		if i == ticks/2 {
+			bj := &BlobJSON{}
+
+			err := json.Unmarshal(saveJSON(t, blobs[2]), bj)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			blobs[2] = NewBlob(bj, graphConf)
+		}
+
+		for _, b := range blobs {
+			b.Update()
+		}
+	}
+
+	if blobs[0].randSource.state == start {
+		t.Fatal("expected the run to use randomness")
+	}
+
+	expected := saveJSON(t, blobs[0])
+
+	for i, b := range blobs[1:] {
+		if !bytes.Equal(saveJSON(t, b), expected) {
+			t.Fatalf("blob %d diverged from blob 0", i+1)
+		}
+	}
+}
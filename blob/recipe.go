@@ -0,0 +1,100 @@
+package blob
+
+import "errors"
+
+type RecipeType string
+
+// RecipeConfig turns input resources into output resources. Nodes and jobs
+// running a recipe add work to its progress until it reaches Duration, then
+// the inputs are taken from the node's stock and the outputs are added to it.
+type RecipeConfig struct {
+	Inputs   map[ResourceType]int `json:"inputs"`
+	Outputs  map[ResourceType]int `json:"outputs"`
+	Duration float64              `json:"duration"`
+}
+
+// CanRunRecipe returns whether the node has the recipe's inputs in stock and
+// room for its outputs.
+func (n *Node) CanRunRecipe(recipeType RecipeType) bool {
+	recipe := n.blob.conf.Recipes[recipeType]
+	if recipe == nil {
+		return false
+	}
+
+	var inputs, outputs int
+
+	for res, count := range recipe.Inputs {
+		if n.ResourceCount(res) < count {
+			return false
+		}
+
+		inputs += count
+	}
+
+	for _, count := range recipe.Outputs {
+		outputs += count
+	}
+
+	return n.AvailableCapacity() >= outputs-inputs
+}
+
+// RunRecipe takes the recipe's inputs from the node's stock and adds its
+// outputs.
+func (n *Node) RunRecipe(recipeType RecipeType) error {
+	if !n.CanRunRecipe(recipeType) {
+		return errors.New("recipe inputs or capacity missing")
+	}
+
+	recipe := n.blob.conf.Recipes[recipeType]
+
+	for _, res := range SortedKeys(recipe.Inputs) {
+		for i := 0; i < recipe.Inputs[res]; i++ {
+			n.TakeResource(res)
+		}
+
+		n.blob.emit(&ResourceConsumed{
+			NodeID:   n.id,
+			Resource: res,
+			Count:    recipe.Inputs[res],
+			Recipe:   recipeType,
+		})
+	}
+
+	for _, res := range SortedKeys(recipe.Outputs) {
+		for i := 0; i < recipe.Outputs[res]; i++ {
+			n.AddResource(res)
+		}
+
+		n.blob.emit(&ResourceProduced{
+			NodeID:   n.id,
+			Resource: res,
+			Count:    recipe.Outputs[res],
+			Recipe:   recipeType,
+		})
+	}
+
+	return nil
+}
+
+// nextRecipe returns the first of the node's recipes that can run, an empty
+// recipe type if none can.
+func (n *Node) nextRecipe() RecipeType {
+	for _, recipeType := range n.conf.Recipes {
+		if n.CanRunRecipe(recipeType) {
+			return recipeType
+		}
+	}
+
+	return ""
+}
+
+// hasRecipe returns whether the node's type runs the recipe itself.
+func (n *Node) hasRecipe(recipeType RecipeType) bool {
+	for _, r := range n.conf.Recipes {
+		if r == recipeType {
+			return true
+		}
+	}
+
+	return false
+}
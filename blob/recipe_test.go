@@ -0,0 +1,162 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+var recipeConf = &BlobConfig{
+	Nodes: map[NodeType]*NodeConfig{
+		"mill": {
+			Radius:           5,
+			ResourceCapacity: 3,
+			Consumes:         map[ResourceType]int{"moss": 0},
+			Produces:         map[ResourceType]int{"flour": 0},
+			Recipes:          []RecipeType{"grind"},
+			ProductionSpeed:  1,
+		},
+		"bakery": {
+			Radius:           5,
+			ResourceCapacity: 3,
+			Consumes:         map[ResourceType]int{"moss": 0},
+			Produces:         map[ResourceType]int{"flour": 0},
+			Recipes:          []RecipeType{"bake"},
+			ProductionSpeed:  1,
+		},
+	},
+	Recipes: map[RecipeType]*RecipeConfig{
+		"grind": {
+			Inputs:   map[ResourceType]int{"moss": 2},
+			Outputs:  map[ResourceType]int{"flour": 1},
+			Duration: 5,
+		},
+		"bake": {
+			Inputs:   map[ResourceType]int{"moss": 1},
+			Outputs:  map[ResourceType]int{"flour": 3},
+			Duration: 5,
+		},
+	},
+	Resources: map[ResourceType]*ResourceConfig{"moss": {}, "flour": {}},
+	Unit:      &UnitConfig{TraversalSpeed: 2, MaxHunger: 1e9},
+}
+
+// stocked returns a node of the type holding moss.
+func stocked(t *testing.T, b *Blob, nodeType NodeType, moss int) *Node {
+	t.Helper()
+
+	node := b.Nodes[b.AddNode(pixel.V(0, 0), nodeType)]
+
+	for i := 0; i < moss; i++ {
+		err := node.AddResource("moss")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return node
+}
+
+func checkStock(t *testing.T, node *Node, moss, flour int) {
+	t.Helper()
+
+	if node.ResourceCount("moss") != moss ||
+		node.ResourceCount("flour") != flour {
+		t.Fatalf(
+			"%d moss and %d flour, expected %d and %d",
+			node.ResourceCount("moss"),
+			node.ResourceCount("flour"),
+			moss,
+			flour,
+		)
+	}
+}
+
+func TestNodeRecipe(t *testing.T) {
+	b := NewBlob(&BlobJSON{Seed: 1}, recipeConf)
+	node := stocked(t, b, "mill", 3)
+	all := events(b)
+
+	for i := 0; i < 4; i++ {
+		b.Update()
+	}
+
+	checkStock(t, node, 3, 0)
+
+	b.Update()
+
+	checkStock(t, node, 1, 1)
+
+	if len(*all) != 2 {
+		t.Fatalf("%d events, expected consumed and produced", len(*all))
+	}
+
+	consumed, ok1 := (*all)[0].(*ResourceConsumed)
+	produced, ok2 := (*all)[1].(*ResourceProduced)
+
+	if !ok1 || !ok2 || consumed.Count != 2 || produced.Count != 1 ||
+		consumed.Recipe != "grind" || produced.Recipe != "grind" {
+		t.Fatalf("events %v", *all)
+	}
+
+	// one moss is left, not enough for another run
+	for i := 0; i < 10; i++ {
+		b.Update()
+	}
+
+	checkStock(t, node, 1, 1)
+}
+
+func TestNodeRecipeOutputsDontFit(t *testing.T) {
+	b := NewBlob(&BlobJSON{Seed: 1}, recipeConf)
+	node := stocked(t, b, "bakery", 2)
+
+	// 3 flour for 1 moss needs 2 free slots, there is only 1
+	for i := 0; i < 10; i++ {
+		b.Update()
+	}
+
+	checkStock(t, node, 2, 0)
+
+	if node.productionProgress != 0 {
+		t.Fatal("expected no progress while the outputs don't fit")
+	}
+
+	err := node.TakeResource("moss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		b.Update()
+	}
+
+	checkStock(t, node, 0, 3)
+}
+
+func TestJobRecipe(t *testing.T) {
+	b := NewBlob(&BlobJSON{Seed: 1}, graphConf)
+	farm := stocked(t, b, "farm", 4)
+	job := b.jobs.NodeJobs(farm.id)[0]
+
+	if !job.CanDo() || job.Duration() != 10 {
+		t.Fatal("expected the farming job to be doable in 10 ticks")
+	}
+
+	err := job.Complete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if farm.ResourceCount("moss") != 5 {
+		t.Fatal("expected the job to produce moss")
+	}
+
+	if job.CanDo() {
+		t.Fatal("expected the job to need room for its output")
+	}
+
+	if job.Complete() == nil || farm.ResourceCount("moss") != 5 {
+		t.Fatal("expected a full farm not to produce")
+	}
+}
@@ -0,0 +1,310 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/faiface/pixel"
+)
+
+// Identifiers are the next IDs given to new nodes, resources, jobs and units.
+type Identifiers struct {
+	Nodes     int
+	Resources int
+	Jobs      int
+	Units     int
+}
+
+func (b *Blob) Identifiers() Identifiers {
+	return Identifiers{
+		Nodes:     b.nodesIdentifier,
+		Resources: b.resourcesIdentifier,
+		Jobs:      b.jobsIdentifier,
+		Units:     b.unitsIdentifier,
+	}
+}
+
+// SetIdentifiers sets the next IDs, so that operations done again give out
+// the same IDs. Identifiers are never set to an ID still in use.
+func (b *Blob) SetIdentifiers(ids Identifiers) {
+	b.nodesIdentifier = ids.Nodes
+	b.resourcesIdentifier = ids.Resources
+	b.jobsIdentifier = ids.Jobs
+	b.unitsIdentifier = ids.Units
+
+	for id := range b.Nodes {
+		if id >= b.nodesIdentifier {
+			b.nodesIdentifier = id + 1
+		}
+	}
+
+	for id := range b.Units {
+		if id >= b.unitsIdentifier {
+			b.unitsIdentifier = id + 1
+		}
+	}
+
+	for _, job := range b.jobs.all() {
+		if job.id >= b.jobsIdentifier {
+			b.jobsIdentifier = job.id + 1
+		}
+	}
+}
+
+// Connected returns whether two nodes are connected.
+func (b *Blob) Connected(id1, id2 int) bool {
+	return b.connected[NewConnectionIDs(id1, id2)]
+}
+
+// RestoreConnection adds a removed connection back, without paying its build
+// cost.
+func (b *Blob) RestoreConnection(conn *Connection) error {
+	connIDs, err := b.connectionIDs(conn.Nodes.Node1, conn.Nodes.Node2)
+	if err != nil {
+		return err
+	}
+
+	if b.connected[connIDs] {
+		return errors.New("nodes are already connected")
+	}
+
+	b.addConnection(connIDs, conn.Type)
+
+	return nil
+}
+
+// RefundBuildCost gives the build cost of a connection type back, into nodes
+// with room for it in order of their IDs. What does not fit is lost.
+func (b *Blob) RefundBuildCost(connType ConnectionType) {
+	if connType == "" {
+		connType = b.conf.DefaultConnection
+	}
+
+	conf, ok := b.conf.Connections[connType]
+	if !ok {
+		return
+	}
+
+	ids := SortedKeys(b.Nodes)
+
+	for _, res := range SortedKeys(conf.BuildCost) {
+		left := conf.BuildCost[res]
+
+		for _, id := range ids {
+			for left > 0 && b.Nodes[id].AddResource(res) == nil {
+				left--
+			}
+		}
+	}
+}
+
+// NodeState is a node with its connections and jobs, everything needed to
+// restore it after it was removed or changed.
+type NodeState struct {
+	Node        *NodeJSON
+	Connections []*Connection
+	Jobs        []*JobJSON
+	Consumes    map[ResourceType]int
+	Produces    map[ResourceType]int
+}
+
+func (b *Blob) NodeState(id int) (*NodeState, error) {
+	node, ok := b.Nodes[id]
+	if !ok {
+		return nil, errors.New("node not found")
+	}
+
+	nj := node.ToJSON()
+	nj.Resources = copyResources(node.resources)
+
+	state := &NodeState{
+		Node:     nj,
+		Consumes: nodePriorities(b.consumers, id),
+		Produces: nodePriorities(b.producers, id),
+	}
+
+	for _, conn := range b.adjacency[id] {
+		c := *conn
+		state.Connections = append(state.Connections, &c)
+	}
+
+	for _, job := range b.jobs.NodeJobs(id) {
+		state.Jobs = append(state.Jobs, job.ToJSON())
+	}
+
+	return state, nil
+}
+
+// RestoreNode puts a node back the way it was when its state was taken. A
+// node that still exists is changed back in place, jobs it had then are kept
+// with their units and its other jobs are removed. Connections to nodes that
+// no longer exist are skipped.
+func (b *Blob) RestoreNode(state *NodeState) error {
+	nj := state.Node
+
+	conf, ok := b.conf.Nodes[nj.NodeType]
+	if !ok {
+		return fmt.Errorf("unknown node type %q", nj.NodeType)
+	}
+
+	kept := make(map[int]bool)
+
+	node, ok := b.Nodes[nj.ID]
+	if ok {
+		b.retype(node, nj.NodeType, conf)
+
+		restored := make(map[int]bool, len(state.Jobs))
+		for _, jj := range state.Jobs {
+			restored[jj.ID] = true
+		}
+
+		for _, job := range b.jobs.NodeJobs(nj.ID) {
+			if restored[job.id] {
+				kept[job.id] = true
+				continue
+			}
+
+			b.removeJob(job)
+		}
+
+		b.MoveNode(nj.ID, nj.Pos)
+	} else {
+		node = NewNode(&NodeJSON{ID: nj.ID, Pos: nj.Pos}, b, b.conf)
+		b.retype(node, nj.NodeType, conf)
+
+		b.Nodes[node.id] = node
+		if node.id >= b.nodesIdentifier {
+			b.nodesIdentifier = node.id + 1
+		}
+	}
+
+	// priorities changed in the inspector, the type's are kept otherwise
+	for _, res := range SortedKeys(state.Consumes) {
+		_, _ = setPriority(b.consumers, nj.ID, res, state.Consumes[res], -1)
+	}
+
+	for _, res := range SortedKeys(state.Produces) {
+		_, _ = setPriority(b.producers, nj.ID, res, state.Produces[res], -1)
+	}
+
+	node.resources = copyResources(nj.Resources)
+	node.recipe = nj.Recipe
+	node.productionProgress = nj.ProductionProgress
+
+	for _, jj := range state.Jobs {
+		if kept[jj.ID] {
+			continue
+		}
+
+		b.jobs.Add(NewJob(jj, b.conf, b))
+
+		if jj.ID >= b.jobsIdentifier {
+			b.jobsIdentifier = jj.ID + 1
+		}
+	}
+
+	for _, conn := range state.Connections {
+		other := conn.Nodes.Opposite(nj.ID)
+		if b.Nodes[other] == nil || b.connected[conn.Nodes] {
+			continue
+		}
+
+		b.addConnection(conn.Nodes, conn.Type)
+	}
+
+	return nil
+}
+
+// RemoveUnit removes a unit, its job is given back to the queue.
+func (b *Blob) RemoveUnit(id int) error {
+	u, ok := b.Units[id]
+	if !ok {
+		return errors.New("unit not found")
+	}
+
+	if u.job != nil {
+		b.jobs.Release(u.job)
+	}
+
+	u.setTraversingConnection(nil)
+
+	delete(b.Units, id)
+
+	return nil
+}
+
+// RestoreUnit adds a removed unit back with its ID, hunger and carried
+// resource. It starts wandering from the node it was on.
+func (b *Blob) RestoreUnit(uj *UnitJSON) error {
+	if _, ok := b.Units[uj.ID]; ok {
+		return fmt.Errorf("unit %d already exists", uj.ID)
+	}
+
+	if b.Nodes[uj.NodeID] == nil {
+		return errors.New("node not found")
+	}
+
+	u := NewUnit(
+		&UnitJSON{
+			ID:       uj.ID,
+			NodeID:   uj.NodeID,
+			Resource: uj.Resource,
+			Hunger:   uj.Hunger,
+		},
+		b,
+	)
+	u.SetCurrentProcedureStep(Wander)
+	u.prevPos = u.Pos()
+
+	b.Units[u.id] = u
+
+	if u.id >= b.unitsIdentifier {
+		b.unitsIdentifier = u.id + 1
+	}
+
+	return nil
+}
+
+// Resources returns a copy of the stock of all nodes by node ID.
+func (b *Blob) Resources() map[int]map[ResourceType][]pixel.Vec {
+	stock := make(map[int]map[ResourceType][]pixel.Vec)
+
+	for id, node := range b.Nodes {
+		stock[id] = copyResources(node.resources)
+	}
+
+	return stock
+}
+
+// RestoreResources adds stock taken by Resources back to the nodes, as far as
+// they have room for it.
+func (b *Blob) RestoreResources(stock map[int]map[ResourceType][]pixel.Vec) {
+	for _, id := range SortedKeys(stock) {
+		node, ok := b.Nodes[id]
+		if !ok {
+			continue
+		}
+
+		for _, res := range SortedKeys(stock[id]) {
+			for _, pos := range stock[id][res] {
+				if node.AvailableCapacity() <= 0 {
+					break
+				}
+
+				node.resources[res] = append(node.resources[res], pos)
+			}
+		}
+	}
+}
+
+func copyResources(
+	resources map[ResourceType][]pixel.Vec,
+) map[ResourceType][]pixel.Vec {
+	c := make(map[ResourceType][]pixel.Vec, len(resources))
+
+	for res, positions := range resources {
+		c[res] = append([]pixel.Vec(nil), positions...)
+	}
+
+	return c
+}
@@ -0,0 +1,243 @@
+package blob
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// graphState returns what undo has to restore of the blob in a comparable
+// form: nodes, connections, jobs, priorities and identifiers.
+func graphState(t *testing.T, b *Blob) string {
+	t.Helper()
+
+	bj := b.ToJSON()
+
+	for _, node := range bj.Nodes {
+		for res, positions := range node.Resources {
+			if len(positions) == 0 {
+				delete(node.Resources, res)
+			}
+		}
+	}
+
+	conns := make([]*Connection, len(bj.Connections))
+	copy(conns, bj.Connections)
+
+	sort.Slice(conns, func(i, j int) bool {
+		if conns[i].Nodes.Node1 != conns[j].Nodes.Node1 {
+			return conns[i].Nodes.Node1 < conns[j].Nodes.Node1
+		}
+
+		return conns[i].Nodes.Node2 < conns[j].Nodes.Node2
+	})
+
+	priorities := func(
+		all map[ResourceType]map[int][]int,
+	) map[ResourceType]map[int][]int {
+		sorted := make(map[ResourceType]map[int][]int)
+
+		for res, levels := range all {
+			for priority, ids := range levels {
+				if len(ids) == 0 {
+					continue
+				}
+
+				if sorted[res] == nil {
+					sorted[res] = make(map[int][]int)
+				}
+
+				sorted[res][priority] = append([]int(nil), ids...)
+				sort.Ints(sorted[res][priority])
+			}
+		}
+
+		return sorted
+	}
+
+	jobs := make(map[int]string)
+	for _, job := range b.jobs.all() {
+		jobs[job.id] = fmt.Sprintf("%s on %d", job.jobType, job.nodeID)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"nodes":       bj.Nodes,
+		"connections": conns,
+		"consumers":   priorities(bj.Consumers),
+		"producers":   priorities(bj.Producers),
+		"jobs":        jobs,
+		"identifiers": b.Identifiers(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// roundTrip does and undoes an operation twice, the way the editor's history
+// does, and checks that undo restores the blob and redo redoes the same.
+func roundTrip(t *testing.T, b *Blob, do, undo func() error) {
+	t.Helper()
+
+	before := graphState(t, b)
+	ids := b.Identifiers()
+
+	err := do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := graphState(t, b)
+	if after == before {
+		t.Fatal("operation changed nothing")
+	}
+
+	for i := 0; i < 2; i++ {
+		err = undo()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b.SetIdentifiers(ids)
+		checkGraph(t, b)
+
+		if got := graphState(t, b); got != before {
+			t.Fatalf("undo restored\n%s\nexpected\n%s", got, before)
+		}
+
+		err = do()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkGraph(t, b)
+
+		if got := graphState(t, b); got != after {
+			t.Fatalf("redo gave\n%s\nexpected\n%s", got, after)
+		}
+	}
+}
+
+func TestRestoreRemovedNode(t *testing.T) {
+	for id := 0; id < 3; id++ {
+		b := lineBlob(3)
+		run(t, b, 150)
+
+		var state *NodeState
+
+		roundTrip(
+			t,
+			b,
+			func() error {
+				var err error
+
+				state, err = b.NodeState(id)
+				if err != nil {
+					return err
+				}
+
+				return b.RemoveNode(id)
+			},
+			func() error {
+				return b.RestoreNode(state)
+			},
+		)
+
+		run(t, b, 100)
+	}
+}
+
+func TestRestoreConnection(t *testing.T) {
+	b := lineBlob(3)
+	run(t, b, 150)
+
+	roundTrip(
+		t,
+		b,
+		func() error {
+			_, err := b.Connect(0, 2, "")
+			return err
+		},
+		func() error {
+			return b.Disconnect(0, 2)
+		},
+	)
+
+	if err := b.RestoreConnection(&Connection{
+		Nodes: NewConnectionIDs(0, 2),
+	}); err == nil {
+		t.Fatal("expected an error restoring an existing connection")
+	}
+}
+
+func TestRestoreNodeType(t *testing.T) {
+	for _, nodeType := range []NodeType{"store", "big_farm"} {
+		b := lineBlob(3)
+		run(t, b, 150)
+
+		var state *NodeState
+
+		roundTrip(
+			t,
+			b,
+			func() error {
+				var err error
+
+				state, err = b.NodeState(0)
+				if err != nil {
+					return err
+				}
+
+				return b.SetNodeType(0, nodeType)
+			},
+			func() error {
+				return b.RestoreNode(state)
+			},
+		)
+	}
+}
+
+func TestRestoreNodeKeepsWorkingUnits(t *testing.T) {
+	b := NewBlob(&BlobJSON{}, graphConf)
+	id := b.AddNode(pixel.V(0, 0), "farm")
+	u := b.AddUnit(id)
+
+	for i := 0; i < 10 && u.job == nil; i++ {
+		b.Update()
+	}
+
+	if u.job == nil {
+		t.Fatal("expected the unit to take the farm's job")
+	}
+
+	job := u.job
+
+	state, err := b.NodeState(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.SetNodeType(id, "big_farm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.RestoreNode(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.job != job || b.jobs.State(job) != JobStateOccupied {
+		t.Fatal("expected the unit to keep working on the job")
+	}
+
+	if u.CurrentProcedureStep().stepType == Wander {
+		t.Fatal("expected the unit not to be reset")
+	}
+
+	checkGraph(t, b)
+}
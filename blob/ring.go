@@ -0,0 +1,37 @@
+package blob
+
+// ring keeps the last items pushed to it, dropping the oldest when full.
+type ring[T any] struct {
+	items []T
+	next  int
+	full  bool
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{items: make([]T, size)}
+}
+
+func (r *ring[T]) push(item T) {
+	if len(r.items) == 0 {
+		return
+	}
+
+	r.items[r.next] = item
+
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// all returns the items, oldest first.
+func (r *ring[T]) all() []T {
+	if !r.full {
+		return append([]T(nil), r.items[:r.next]...)
+	}
+
+	return append(
+		append([]T(nil), r.items[r.next:]...),
+		r.items[:r.next]...,
+	)
+}
@@ -0,0 +1,190 @@
+package blob
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Sample is the state of the colony at a tick. Resources include piles.
+// Produced, consumed and deaths are counted over the ticks since the previous
+// sample.
+type Sample struct {
+	Tick          int                  `json:"tick"`
+	Resources     map[ResourceType]int `json:"resources"`
+	Produced      map[ResourceType]int `json:"produced"`
+	Consumed      map[ResourceType]int `json:"consumed"`
+	Units         int                  `json:"units"`
+	AverageHunger float64              `json:"average_hunger"`
+	Jobs          map[JobState]int     `json:"jobs"`
+	Deaths        int                  `json:"deaths"`
+}
+
+// Stats samples the blob every few ticks, keeping the latest samples. Counts
+// between samples are taken from the blob's events.
+type Stats struct {
+	blob     *Blob
+	interval int
+	samples  *ring[*Sample]
+
+	produced map[ResourceType]int
+	consumed map[ResourceType]int
+	deaths   int
+
+	unsubscribe func()
+}
+
+func NewStats(b *Blob, interval, samples int) *Stats {
+	if interval < 1 {
+		interval = 1
+	}
+
+	s := &Stats{
+		blob:     b,
+		interval: interval,
+		samples:  newRing[*Sample](samples),
+		produced: make(map[ResourceType]int),
+		consumed: make(map[ResourceType]int),
+	}
+
+	s.unsubscribe = b.Subscribe(s.handle)
+
+	return s
+}
+
+func (s *Stats) handle(_ int, event Event) {
+	switch e := event.(type) {
+	case *ResourceProduced:
+		s.produced[e.Resource] += e.Count
+	case *ResourceConsumed:
+		s.consumed[e.Resource] += e.Count
+	case *UnitDied:
+		s.deaths++
+	}
+}
+
+// Update takes a sample if one is due, it is called after every blob update.
+func (s *Stats) Update() {
+	if s.blob.tick%s.interval != 0 {
+		return
+	}
+
+	sample := &Sample{
+		Tick:      s.blob.tick,
+		Resources: make(map[ResourceType]int),
+		Produced:  s.produced,
+		Consumed:  s.consumed,
+		Units:     len(s.blob.Units),
+		Jobs:      make(map[JobState]int),
+		Deaths:    s.deaths,
+	}
+
+	for _, node := range s.blob.Nodes {
+		for res, positions := range node.resources {
+			sample.Resources[res] += len(positions)
+		}
+	}
+
+	for _, pile := range s.blob.piles {
+		sample.Resources[pile.resource]++
+	}
+
+	for _, id := range SortedKeys(s.blob.Units) {
+		sample.AverageHunger += s.blob.Units[id].hunger
+	}
+
+	if len(s.blob.Units) > 0 {
+		sample.AverageHunger /= float64(len(s.blob.Units))
+	}
+
+	for _, job := range s.blob.jobs.all() {
+		sample.Jobs[s.blob.jobs.State(job)]++
+	}
+
+	s.samples.push(sample)
+
+	s.produced = make(map[ResourceType]int)
+	s.consumed = make(map[ResourceType]int)
+	s.deaths = 0
+}
+
+// Interval returns the number of ticks between samples.
+func (s *Stats) Interval() int {
+	return s.interval
+}
+
+// Samples returns the kept samples, oldest first.
+func (s *Stats) Samples() []*Sample {
+	return s.samples.all()
+}
+
+// Close stops counting the blob's events.
+func (s *Stats) Close() {
+	s.unsubscribe()
+}
+
+func (s *Stats) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+
+	return encoder.Encode(s.Samples())
+}
+
+// WriteCSV writes a row per sample, with a column per resource type in config
+// for resources, production and consumption.
+func (s *Stats) WriteCSV(w io.Writer) error {
+	resources := SortedKeys(s.blob.conf.Resources)
+	states := []JobState{JobStateAvailable, JobStateOccupied, JobStateHalted}
+
+	header := []string{"tick", "units", "average_hunger", "deaths"}
+
+	for _, state := range states {
+		header = append(header, "jobs_"+string(state))
+	}
+
+	for _, prefix := range []string{"resources_", "produced_", "consumed_"} {
+		for _, res := range resources {
+			header = append(header, prefix+string(res))
+		}
+	}
+
+	cw := csv.NewWriter(w)
+
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, sample := range s.Samples() {
+		row := []string{
+			strconv.Itoa(sample.Tick),
+			strconv.Itoa(sample.Units),
+			strconv.FormatFloat(sample.AverageHunger, 'f', 2, 64),
+			strconv.Itoa(sample.Deaths),
+		}
+
+		for _, state := range states {
+			row = append(row, strconv.Itoa(sample.Jobs[state]))
+		}
+
+		for _, counts := range []map[ResourceType]int{
+			sample.Resources,
+			sample.Produced,
+			sample.Consumed,
+		} {
+			for _, res := range resources {
+				row = append(row, strconv.Itoa(counts[res]))
+			}
+		}
+
+		err = cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,169 @@
+package blob
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		size     int
+		pushes   int
+		expected []int
+	}{
+		{0, 3, []int{}},
+		{1, 3, []int{2}},
+		{3, 0, []int{}},
+		{3, 2, []int{0, 1}},
+		{3, 3, []int{0, 1, 2}},
+		{3, 5, []int{2, 3, 4}},
+		{3, 7, []int{4, 5, 6}},
+	}
+
+	for _, test := range tests {
+		r := newRing[int](test.size)
+		for i := 0; i < test.pushes; i++ {
+			r.push(i)
+		}
+
+		all := r.all()
+		if fmt.Sprint(all) != fmt.Sprint(test.expected) {
+			t.Errorf(
+				"size %d after %d pushes: %v, expected %v",
+				test.size,
+				test.pushes,
+				all,
+				test.expected,
+			)
+		}
+	}
+}
+
+func sampleTicks(s *Stats) []int {
+	var ticks []int
+	for _, sample := range s.Samples() {
+		ticks = append(ticks, sample.Tick)
+	}
+
+	return ticks
+}
+
+func TestStatsInterval(t *testing.T) {
+	tests := []struct {
+		interval int
+		samples  int
+		expected []int
+	}{
+		{3, 10, []int{3, 6, 9}},
+		{3, 2, []int{6, 9}},
+		{0, 3, []int{8, 9, 10}}, // every tick
+		{1, 0, nil},
+	}
+
+	for _, test := range tests {
+		b := NewBlob(&BlobJSON{Seed: 1}, graphConf)
+		s := NewStats(b, test.interval, test.samples)
+
+		for i := 0; i < 10; i++ {
+			b.Update()
+			s.Update()
+		}
+
+		ticks := sampleTicks(s)
+		if fmt.Sprint(ticks) != fmt.Sprint(test.expected) {
+			t.Errorf(
+				"interval %d, %d samples: ticks %v, expected %v",
+				test.interval,
+				test.samples,
+				ticks,
+				test.expected,
+			)
+		}
+	}
+}
+
+func TestStatsCountsReset(t *testing.T) {
+	b := NewBlob(&BlobJSON{Seed: 1}, graphConf)
+	s := NewStats(b, 2, 10)
+
+	b.emit(&ResourceProduced{Resource: "moss", Count: 2})
+	b.emit(&ResourceConsumed{Resource: "moss", Count: 1})
+	b.emit(&UnitDied{})
+
+	for i := 0; i < 2; i++ {
+		b.Update()
+		s.Update()
+	}
+
+	b.emit(&ResourceProduced{Resource: "moss", Count: 3})
+
+	for i := 0; i < 2; i++ {
+		b.Update()
+		s.Update()
+	}
+
+	samples := s.Samples()
+	if len(samples) != 2 {
+		t.Fatalf("%d samples, expected 2", len(samples))
+	}
+
+	first, second := samples[0], samples[1]
+
+	if first.Produced["moss"] != 2 || first.Consumed["moss"] != 1 ||
+		first.Deaths != 1 {
+		t.Fatalf("first sample %+v", first)
+	}
+
+	if second.Produced["moss"] != 3 || second.Consumed["moss"] != 0 ||
+		second.Deaths != 0 {
+		t.Fatalf("second sample %+v, expected the counts to reset", second)
+	}
+}
+
+func TestStatsCSV(t *testing.T) {
+	conf := *graphConf
+	conf.Resources = map[ResourceType]*ResourceConfig{
+		"wood": {},
+		"moss": {},
+	}
+
+	b := NewBlob(&BlobJSON{Seed: 1}, &conf)
+	farm := b.AddNode(pixel.V(0, 0), "farm")
+
+	err := b.Nodes[farm].AddResource("moss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStats(b, 1, 10)
+
+	b.emit(&ResourceProduced{Resource: "wood", Count: 2})
+	b.emit(&ResourceConsumed{Resource: "moss", Count: 1})
+	b.Update()
+	s.Update()
+
+	var buf bytes.Buffer
+
+	err = s.WriteCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"tick,units,average_hunger,deaths," +
+			"jobs_available,jobs_occupied,jobs_halted," +
+			"resources_moss,resources_wood," +
+			"produced_moss,produced_wood," +
+			"consumed_moss,consumed_wood",
+		"1,0,0.00,0,1,0,0,1,0,0,2,1,0",
+		"",
+	}, "\n")
+
+	if buf.String() != expected {
+		t.Fatalf("csv:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
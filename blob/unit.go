@@ -1,7 +1,6 @@
 package blob
 
 import (
-	"fmt"
 	"image/color"
 
 	"private/grow/render"
@@ -21,6 +20,8 @@ const (
 	PickUpResource ProcedureStepType = "pick_up_resource"
 	DropResource   ProcedureStepType = "drop_resource"
 	FindConsumer   ProcedureStepType = "find_consumer"
+	AwaitConsumer  ProcedureStepType = "await_consumer"
+	PickUpPile     ProcedureStepType = "pick_up_pile"
 	FindJob        ProcedureStepType = "find_job"
 	DoJob          ProcedureStepType = "do_job"
 	FindTask       ProcedureStepType = "find_task"
@@ -32,12 +33,16 @@ type ProcedureStep struct {
 	stepType     ProcedureStepType
 	nodeID       int
 	resourceType ResourceType
+	pileID       int
+	ticks        int // left to wait
 }
 
 type ProcedureStepJSON struct {
 	StepType     ProcedureStepType `json:"step_type"`
 	NodeID       int               `json:"node_id"`
 	ResourceType ResourceType      `json:"resource_type"`
+	PileID       int               `json:"pile_id"`
+	Ticks        int               `json:"ticks"`
 }
 
 func NewProcedureStep(psj *ProcedureStepJSON) *ProcedureStep {
@@ -45,6 +50,8 @@ func NewProcedureStep(psj *ProcedureStepJSON) *ProcedureStep {
 		stepType:     psj.StepType,
 		nodeID:       psj.NodeID,
 		resourceType: psj.ResourceType,
+		pileID:       psj.PileID,
+		ticks:        psj.Ticks,
 	}
 }
 
@@ -53,6 +60,8 @@ func (ps *ProcedureStep) ToJSON() *ProcedureStepJSON {
 		StepType:     ps.stepType,
 		NodeID:       ps.nodeID,
 		ResourceType: ps.resourceType,
+		PileID:       ps.pileID,
+		Ticks:        ps.ticks,
 	}
 }
 
@@ -60,6 +69,8 @@ type UnitConfig struct {
 	TraversalSpeed float64 `json:"traversal_speed"`
 	HungerRate     float64 `json:"hunger_rate"`
 	MaxHunger      float64 `json:"max_hunger"`
+
+	Overflow *OverflowConfig `json:"overflow"`
 }
 
 type Unit struct {
@@ -78,12 +89,17 @@ type Unit struct {
 	stationaryLerpProgress float64
 
 	resource ResourceType
+	retries  int // of finding a consumer for the resource
 
 	job         *Job
 	jobProgress float64
 
 	hunger float64
 
+	prevPos pixel.Vec // position before the last tick, used to interpolate
+
+	trace *ring[*StepTransition] // not saved, only for inspecting units
+
 	blob *Blob
 	conf *UnitConfig
 }
@@ -103,6 +119,7 @@ type UnitJSON struct {
 	StationaryLerpProgress float64   `json:"stationary_lerp_progress"`
 
 	Resource ResourceType `json:"resource"`
+	Retries  int          `json:"retries"`
 
 	Job         *JobJSON `json:"job"`
 	JobProgress float64  `json:"job_progress"`
@@ -126,12 +143,15 @@ func NewUnit(uj *UnitJSON, blob *Blob) *Unit {
 		stationaryLerpProgress: uj.StationaryLerpProgress,
 
 		resource: uj.Resource,
+		retries:  uj.Retries,
 
 		job:         NewJob(uj.Job, blob.conf, blob),
 		jobProgress: uj.JobProgress,
 
 		hunger: uj.Hunger,
 
+		trace: newRing[*StepTransition](unitTraceLength),
+
 		blob: blob,
 		conf: blob.conf.Unit,
 	}
@@ -159,6 +179,7 @@ func (u *Unit) ToJSON() *UnitJSON {
 		StationaryLerpProgress: u.stationaryLerpProgress,
 
 		Resource: u.resource,
+		Retries:  u.retries,
 
 		Job:         u.job.ToJSON(),
 		JobProgress: u.jobProgress,
@@ -173,8 +194,43 @@ func (u *Unit) ToJSON() *UnitJSON {
 	return uj
 }
 
-func (u *Unit) Render(rend *render.Renderer) {
-	pos := u.Pos()
+func (u *Unit) ID() int {
+	return u.id
+}
+
+// StepTransition is a change of a unit's current procedure step.
+type StepTransition struct {
+	Tick   int
+	From   ProcedureStepType
+	To     ProcedureStepType
+	NodeID int // node the unit was on
+}
+
+// unitTraceLength is the number of recent step transitions kept per unit.
+const unitTraceLength = 16
+
+// traceStep records a transition if the current step changed from the step
+// before.
+func (u *Unit) traceStep(before *ProcedureStep) {
+	if len(u.procedure) == 0 {
+		return
+	}
+
+	after := u.CurrentProcedureStep()
+	if after.stepType == before.stepType && after.nodeID == before.nodeID {
+		return
+	}
+
+	u.trace.push(&StepTransition{
+		Tick:   u.blob.tick,
+		From:   before.stepType,
+		To:     after.stepType,
+		NodeID: u.nodeID,
+	})
+}
+
+func (u *Unit) Render(rend *render.Renderer, alpha float64) {
+	pos := pixel.Lerp(u.prevPos, u.Pos(), alpha)
 
 	rend.Circle(pos, color.RGBA{50, 50, 50, 255}, 6, 0)
 
@@ -190,6 +246,10 @@ func (u *Unit) Pos() pixel.Vec {
 		return u.stationaryPos
 
 	case Traverse:
+		if u.traversingConnection == nil { // waiting to enter
+			break
+		}
+
 		start := u.blob.Nodes[u.nodeID].pos
 		target := u.blob.Nodes[u.traversingConnection.Nodes.Opposite(u.nodeID)].pos
 
@@ -221,11 +281,11 @@ func (u *Unit) Update() {
 		u.SetCurrentProcedureStep(FindJob)
 	case Wander:
 		var nodes []*Node
-		for _, node := range u.blob.Nodes {
-			if node.id == u.nodeID {
+		for _, id := range SortedKeys(u.blob.Nodes) {
+			if id == u.nodeID {
 				continue
 			}
-			nodes = append(nodes, node)
+			nodes = append(nodes, u.blob.Nodes[id])
 		}
 
 		if len(nodes) == 0 {
@@ -233,7 +293,10 @@ func (u *Unit) Update() {
 			return
 		}
 
-		path, err := u.blob.Dijkstra(u.nodeID, RandomSliceElement(nodes).id)
+		path, err := u.blob.FindPath(
+			u.nodeID,
+			RandomSliceElement(u.blob.rand, nodes).id,
+		)
 		if err != nil {
 			u.SetCurrentProcedureStep(Wander)
 			return
@@ -249,14 +312,12 @@ func (u *Unit) Update() {
 		u.SetCurrentProcedureStep(Traverse)
 
 	case TraverseTo:
-		path, err := u.blob.Dijkstra(u.nodeID, u.CurrentProcedureStep().nodeID)
+		path, err := u.blob.FindPath(
+			u.nodeID,
+			u.CurrentProcedureStep().nodeID,
+		)
 		if err != nil {
-			if u.job != nil {
-				u.blob.jobs.Halt(u.job)
-			}
-
-			u.ClearProcedure()
-			u.SetCurrentProcedureStep(Wander)
+			u.reset()
 			return
 		}
 
@@ -269,28 +330,52 @@ func (u *Unit) Update() {
 		u.SetCurrentProcedureStep(Traverse)
 
 	case Traverse:
-		u.traversingProgress += u.conf.TraversalSpeed
+		if u.traversingConnection == nil && !u.enterConnection() {
+			return
+		}
+
+		u.traversingProgress += u.conf.TraversalSpeed *
+			u.blob.connectionConfig(u.traversingConnection.Type).SpeedMultiplier
 
 		if u.traversingProgress >= u.traversingConnection.Length {
 			u.traversingStep++
 
 			u.nodeID = u.traversingConnection.Nodes.Opposite(u.nodeID)
 			u.traversingProgress = 0
+			u.setTraversingConnection(nil)
 
 			if u.traversingStep >= len(u.traversingPath) {
 				u.traversingPath = nil
-				u.traversingConnection = nil
 				u.traversingStep = 0
 
 				u.NextProcedureStep()
 				return
 			}
 
-			u.traversingConnection = u.blob.GetConnection(
-				NewConnectionIDs(u.nodeID, u.traversingPath[u.traversingStep]),
-			)
+			u.enterConnection()
 		}
 	case StartCarry:
+		if pile, ok := u.blob.collectablePile(); ok {
+			u.procedure = []*ProcedureStep{
+				{
+					stepType: TraverseTo,
+					nodeID:   pile.nodeID,
+				},
+				{
+					stepType: PickUpPile,
+					pileID:   pile.id,
+				},
+				{
+					stepType: FindConsumer,
+				},
+				{
+					stepType: DropResource,
+				},
+			}
+
+			return
+		}
+
 		id, resourceType, err := u.blob.GetProducerNodeID()
 		if err != nil {
 			u.ClearProcedure()
@@ -324,15 +409,21 @@ func (u *Unit) Update() {
 
 		u.jobProgress += u.job.conf.ProductionSpeed
 
-		if u.jobProgress >= 100 {
+		if u.jobProgress >= u.job.Duration() {
 			u.jobProgress = 0
 			u.blob.jobs.Complete(u.job)
+			u.job = nil
 
 			// find next task or wander
 			u.ClearProcedure()
 			u.SetCurrentProcedureStep(Wander)
 		}
 	case PickUpResource:
+		if u.resource != ResourceTypeNone { // only one is carried at a time
+			u.deliver()
+			return
+		}
+
 		err := u.blob.Nodes[u.nodeID].TakeResource(
 			u.CurrentProcedureStep().resourceType,
 		)
@@ -344,23 +435,49 @@ func (u *Unit) Update() {
 
 		u.resource = u.CurrentProcedureStep().resourceType
 
+		u.blob.emit(&ResourcePickedUp{
+			UnitID:   u.id,
+			NodeID:   u.nodeID,
+			Resource: u.resource,
+		})
+
+		u.NextProcedureStep()
+
+	case PickUpPile:
+		if u.resource != ResourceTypeNone {
+			u.deliver()
+			return
+		}
+
+		pile, ok := u.blob.piles[u.CurrentProcedureStep().pileID]
+		if !ok || pile.nodeID != u.nodeID {
+			u.ClearProcedure()
+			u.SetCurrentProcedureStep(Wander)
+			return
+		}
+
+		delete(u.blob.piles, pile.id)
+		u.resource = pile.resource
+
+		u.blob.emit(&PileCollected{
+			UnitID:   u.id,
+			NodeID:   u.nodeID,
+			PileID:   pile.id,
+			Resource: u.resource,
+		})
+
 		u.NextProcedureStep()
 
 	case FindConsumer:
 		consumerNodeID, err := u.blob.GetConsumerNodeID(u.resource)
 		if err != nil {
-			// TODO: figure out what to do here. carried resource gets lost.
-			u.resource = ResourceTypeNone
-			u.ClearProcedure()
-			u.SetCurrentProcedureStep(Wander)
+			u.overflow("no consumer")
 			return
 		}
 
-		path, err := u.blob.Dijkstra(u.nodeID, consumerNodeID)
+		path, err := u.blob.FindPath(u.nodeID, consumerNodeID)
 		if err != nil {
-			u.resource = ResourceTypeNone
-			u.ClearProcedure()
-			u.SetCurrentProcedureStep(Wander)
+			u.overflow("no path to consumer")
 			return
 		}
 
@@ -380,10 +497,30 @@ func (u *Unit) Update() {
 			return
 		}
 
+		u.blob.emit(&ResourceDropped{
+			UnitID:   u.id,
+			NodeID:   u.nodeID,
+			Resource: u.resource,
+		})
+
 		u.resource = ResourceTypeNone
+		u.retries = 0
 
 		u.NextProcedureStep()
 
+	case AwaitConsumer:
+		if u.hunger > u.conf.MaxHunger {
+			u.Die()
+			return
+		}
+
+		step := u.CurrentProcedureStep()
+
+		step.ticks--
+		if step.ticks <= 0 {
+			u.NextProcedureStep()
+		}
+
 	case FindJob:
 		job, err := u.blob.jobs.GetJob()
 		if err != nil {
@@ -433,7 +570,12 @@ func (u *Unit) Update() {
 			u.SetCurrentProcedureStep(Wander)
 			return
 		}
-		fmt.Println("eat")
+
+		u.blob.emit(&ResourceConsumed{
+			NodeID:   u.nodeID,
+			Resource: ResourceTypeMushroom,
+			Count:    1,
+		})
 
 		u.hunger = 0
 		u.SetCurrentProcedureStep(FindTask)
@@ -484,16 +626,173 @@ func (u *Unit) StationaryLerp() {
 
 func (u *Unit) SetTraversalPath(path []int) {
 	u.traversingPath = path
-	u.traversingConnection = u.blob.GetConnection(
-		NewConnectionIDs(u.nodeID, path[0]),
+	u.setTraversingConnection(nil)
+	u.traversingStep = 0
+	u.traversingProgress = 0
+
+	u.enterConnection()
+}
+
+// enterConnection moves the unit onto the next connection of its path. It
+// returns false while the connection is full, the unit waits at its node. A
+// connection that is gone has the unit reroute, it returns whether the new
+// path got the unit onto a connection.
+func (u *Unit) enterConnection() bool {
+	conn := u.blob.GetConnection(
+		NewConnectionIDs(u.nodeID, u.traversingPath[u.traversingStep]),
 	)
+
+	if conn == nil {
+		u.reroute()
+		return u.traversingConnection != nil
+	}
+
+	if u.blob.connectionFull(conn) {
+		return false
+	}
+
+	u.setTraversingConnection(conn)
+
+	return true
+}
+
+// setTraversingConnection moves the unit onto a connection, keeping the blob's
+// traffic counts in sync.
+func (u *Unit) setTraversingConnection(conn *Connection) {
+	if u.traversingConnection != nil {
+		connIDs := u.traversingConnection.Nodes
+
+		u.blob.traffic[connIDs]--
+		if u.blob.traffic[connIDs] <= 0 {
+			delete(u.blob.traffic, connIDs)
+		}
+	}
+
+	u.traversingConnection = conn
+
+	if conn != nil {
+		u.blob.traffic[conn.Nodes]++
+	}
+}
+
+// pathValid returns whether the rest of the unit's path is still in the graph.
+func (u *Unit) pathValid() bool {
+	from := u.nodeID
+
+	for _, id := range u.traversingPath[u.traversingStep:] {
+		if !u.blob.connected[NewConnectionIDs(from, id)] {
+			return false
+		}
+
+		from = id
+	}
+
+	return true
+}
+
+// reroute finds a new path to the destination of the unit's path. A unit
+// traversing a connection finishes it first. Units that can no longer reach
+// their destination are reset.
+func (u *Unit) reroute() {
+	dest := u.traversingPath[len(u.traversingPath)-1]
+	from := u.nodeID
+
+	var prefix []int
+
+	if u.traversingConnection != nil {
+		from = u.traversingConnection.Nodes.Opposite(u.nodeID)
+		prefix = []int{from}
+	}
+
+	path, err := u.blob.FindPath(from, dest)
+	if err != nil {
+		u.reset()
+		return
+	}
+
+	path = append(prefix, path...)
+
+	if u.traversingConnection != nil {
+		u.traversingPath = path
+		u.traversingStep = 0
+		return
+	}
+
+	if len(path) == 0 { // already at the destination
+		u.traversingPath = nil
+		u.traversingStep = 0
+
+		u.NextProcedureStep()
+		return
+	}
+
+	u.SetTraversalPath(path)
+}
+
+// heading returns whether the unit is on its way to the node, or its procedure
+// leads there.
+func (u *Unit) heading(nodeID int) bool {
+	if len(u.traversingPath) > 0 &&
+		u.traversingPath[len(u.traversingPath)-1] == nodeID {
+		return true
+	}
+
+	for _, step := range u.procedure {
+		if step.stepType == TraverseTo && step.nodeID == nodeID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reset drops the unit's procedure and path, it wanders off from the node it
+// is on once it delivered what it carries. Its job is halted.
+func (u *Unit) reset() {
+	if u.job != nil {
+		u.blob.jobs.Halt(u.job)
+		u.job = nil
+		u.jobProgress = 0
+	}
+
+	u.traversingPath = nil
+	u.setTraversingConnection(nil)
 	u.traversingStep = 0
 	u.traversingProgress = 0
+
+	if u.resource != ResourceTypeNone {
+		u.deliver()
+		return
+	}
+
+	u.ClearProcedure()
+	u.SetCurrentProcedureStep(Wander)
+}
+
+// deliver makes the unit look for a consumer of the resource it carries and
+// drop it there.
+func (u *Unit) deliver() {
+	u.procedure = []*ProcedureStep{
+		{
+			stepType: FindConsumer,
+		},
+		{
+			stepType: DropResource,
+		},
+	}
 }
 
+// Die removes the unit, what it carries is dropped where it died.
 func (u *Unit) Die() {
-	fmt.Println("unit died")
-	u.blob.jobs.Complete(u.job)
+	u.dropResource("unit died")
+
+	u.blob.emit(&UnitDied{UnitID: u.id, NodeID: u.nodeID, Hunger: u.hunger})
+
+	if u.job != nil {
+		u.blob.jobs.Complete(u.job)
+	}
+
+	u.setTraversingConnection(nil)
 
 	delete(u.blob.Units, u.id)
 }
@@ -0,0 +1,940 @@
+package blob
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"private/grow/render"
+)
+
+// ValidationError is a problem found in a saved blob. Path points to the
+// offending value using the save's JSON keys, e.g. units.12.node.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Validate walks the whole save and reports every inconsistency in it.
+func (bj *BlobJSON) Validate(conf *BlobConfig) []*ValidationError {
+	v := &validator{bj: bj, conf: conf}
+	v.validate()
+
+	return v.errs
+}
+
+// Repair drops or resets everything Validate would report, so that the save
+// can be loaded. The fixed problems are returned.
+func (bj *BlobJSON) Repair(conf *BlobConfig) []*ValidationError {
+	v := &validator{bj: bj, conf: conf, repair: true}
+	v.validate()
+
+	return v.errs
+}
+
+type validator struct {
+	bj        *BlobJSON
+	conf      *BlobConfig
+	repair    bool
+	connected map[ConnectionIDs]bool
+	errs      []*ValidationError
+}
+
+func (v *validator) report(path []string, format string, args ...any) {
+	v.errs = append(v.errs, &ValidationError{
+		Path:    strings.Join(path, "."),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validate() {
+	v.validateNodes()
+	v.validateConnections()
+	v.validateJobs()
+	v.validateUnits()
+	v.validatePiles()
+	v.validatePriorities("consumers", v.bj.Consumers)
+	v.validatePriorities("producers", v.bj.Producers)
+}
+
+func (v *validator) validateNodes() {
+	if v.bj.Nodes == nil {
+		v.bj.Nodes = make(map[int]*NodeJSON)
+	}
+
+	maxID := -1
+
+	for _, id := range SortedKeys(v.bj.Nodes) {
+		path := []string{"nodes", strconv.Itoa(id)}
+		node := v.bj.Nodes[id]
+
+		if node == nil {
+			v.report(path, "node is empty")
+
+			if v.repair {
+				delete(v.bj.Nodes, id)
+			}
+
+			continue
+		}
+
+		if v.conf.Nodes[node.NodeType] == nil {
+			v.report(
+				append(path, "node_type"),
+				"unknown node type %q",
+				node.NodeType,
+			)
+
+			if v.repair {
+				delete(v.bj.Nodes, id)
+			}
+
+			continue
+		}
+
+		if node.ID != id {
+			v.report(append(path, "id"), "id %d does not match key", node.ID)
+
+			if v.repair {
+				node.ID = id
+			}
+		}
+
+		if node.Recipe != "" && v.conf.Recipes[node.Recipe] == nil {
+			v.report(
+				append(path, "recipe"),
+				"unknown recipe %q",
+				node.Recipe,
+			)
+
+			if v.repair {
+				node.Recipe = ""
+				node.ProductionProgress = 0
+			}
+		}
+
+		for _, res := range SortedKeys(node.Resources) {
+			if v.conf.Resources[res] != nil {
+				continue
+			}
+
+			v.report(
+				append(path, "resources", string(res)),
+				"unknown resource type %q",
+				res,
+			)
+
+			if v.repair {
+				delete(node.Resources, res)
+			}
+		}
+
+		if id > maxID {
+			maxID = id
+		}
+	}
+
+	if v.bj.NodesIdentifier <= maxID {
+		v.report(
+			[]string{"nodes_identifier"},
+			"identifier %d is not greater than largest node id %d",
+			v.bj.NodesIdentifier,
+			maxID,
+		)
+
+		if v.repair {
+			v.bj.NodesIdentifier = maxID + 1
+		}
+	}
+}
+
+func (v *validator) validateConnections() {
+	connections := make([]*Connection, 0, len(v.bj.Connections))
+	v.connected = make(map[ConnectionIDs]bool)
+
+	for i, conn := range v.bj.Connections {
+		path := []string{"connections", strconv.Itoa(i)}
+
+		if conn == nil {
+			v.report(path, "connection is empty")
+			continue
+		}
+
+		if v.bj.Nodes[conn.Nodes.Node1] == nil {
+			v.report(
+				append(path, "nodes", "node_1"),
+				"node %d does not exist",
+				conn.Nodes.Node1,
+			)
+
+			continue
+		}
+
+		if v.bj.Nodes[conn.Nodes.Node2] == nil {
+			v.report(
+				append(path, "nodes", "node_2"),
+				"node %d does not exist",
+				conn.Nodes.Node2,
+			)
+
+			continue
+		}
+
+		if conn.Nodes.Node1 == conn.Nodes.Node2 {
+			v.report(append(path, "nodes"), "node connected to itself")
+			continue
+		}
+
+		connIDs := NewConnectionIDs(conn.Nodes.Node1, conn.Nodes.Node2)
+
+		if connIDs != conn.Nodes {
+			v.report(append(path, "nodes"), "node ids are not in order")
+
+			if v.repair {
+				conn.Nodes = connIDs
+			}
+		}
+
+		if v.connected[connIDs] {
+			v.report(path, "duplicate connection")
+			continue
+		}
+
+		if conn.Type != "" && v.conf.Connections[conn.Type] == nil {
+			v.report(
+				append(path, "type"),
+				"unknown connection type %q",
+				conn.Type,
+			)
+
+			if v.repair {
+				conn.Type = ""
+			}
+		}
+
+		v.connected[connIDs] = true
+		connections = append(connections, conn)
+	}
+
+	if v.repair {
+		v.bj.Connections = connections
+	}
+}
+
+// validJob reports problems of a saved job and returns whether it can be
+// kept.
+func (v *validator) validJob(path []string, job *JobJSON) bool {
+	if job == nil {
+		v.report(path, "job is empty")
+		return false
+	}
+
+	if v.conf.Jobs[job.JobType] == nil {
+		v.report(
+			append(path, "job_type"),
+			"unknown job type %q",
+			job.JobType,
+		)
+
+		return false
+	}
+
+	if v.bj.Nodes[job.NodeID] == nil {
+		v.report(
+			append(path, "node_id"),
+			"node %d does not exist",
+			job.NodeID,
+		)
+
+		return false
+	}
+
+	return true
+}
+
+func (v *validator) validateJobs() {
+	if v.bj.Jobs == nil {
+		v.bj.Jobs = &JobQueueJSON{}
+	}
+
+	queues := []struct {
+		name string
+		jobs map[int]*JobJSON
+	}{
+		{"occupied", v.bj.Jobs.Occupied},
+		{"available", v.bj.Jobs.Available},
+		{"halted", v.bj.Jobs.Halted},
+	}
+
+	maxID := -1
+	seen := make(map[int]bool)
+
+	for _, queue := range queues {
+		for _, id := range SortedKeys(queue.jobs) {
+			path := []string{"jobs", queue.name, strconv.Itoa(id)}
+			job := queue.jobs[id]
+
+			if !v.validJob(path, job) {
+				if v.repair {
+					delete(queue.jobs, id)
+				}
+
+				continue
+			}
+
+			if job.ID != id {
+				v.report(
+					append(path, "id"),
+					"id %d does not match key",
+					job.ID,
+				)
+
+				if v.repair {
+					job.ID = id
+				}
+			}
+
+			if seen[id] {
+				v.report(path, "job is in more than one queue")
+
+				if v.repair {
+					delete(queue.jobs, id)
+				}
+
+				continue
+			}
+
+			seen[id] = true
+
+			if id > maxID {
+				maxID = id
+			}
+		}
+	}
+
+	if v.bj.JobsIdentifier <= maxID {
+		v.report(
+			[]string{"jobs_identifier"},
+			"identifier %d is not greater than largest job id %d",
+			v.bj.JobsIdentifier,
+			maxID,
+		)
+
+		if v.repair {
+			v.bj.JobsIdentifier = maxID + 1
+		}
+	}
+}
+
+func (v *validator) validateUnits() {
+	var homeID int
+
+	nodeIDs := SortedKeys(v.bj.Nodes)
+	if len(nodeIDs) > 0 {
+		homeID = nodeIDs[0]
+	}
+
+	maxID := -1
+
+	for _, id := range SortedKeys(v.bj.Units) {
+		path := []string{"units", strconv.Itoa(id)}
+		unit := v.bj.Units[id]
+
+		if unit == nil {
+			v.report(path, "unit is empty")
+
+			if v.repair {
+				delete(v.bj.Units, id)
+			}
+
+			continue
+		}
+
+		if unit.ID != id {
+			v.report(append(path, "id"), "id %d does not match key", unit.ID)
+
+			if v.repair {
+				unit.ID = id
+			}
+		}
+
+		if id > maxID {
+			maxID = id
+		}
+
+		if v.bj.Nodes[unit.NodeID] == nil {
+			v.report(
+				append(path, "node"),
+				"node %d does not exist",
+				unit.NodeID,
+			)
+
+			if !v.repair {
+				continue
+			}
+
+			if len(nodeIDs) == 0 {
+				delete(v.bj.Units, id)
+				continue
+			}
+
+			unit.NodeID = homeID
+			v.resetUnit(unit)
+
+			continue
+		}
+
+		if unit.Job != nil && !v.validJob(append(path, "job"), unit.Job) {
+			if v.repair {
+				unit.Job = nil
+				v.resetUnit(unit)
+			}
+		}
+
+		if unit.Resource != ResourceTypeNone &&
+			v.conf.Resources[unit.Resource] == nil {
+			v.report(
+				append(path, "resource"),
+				"unknown resource type %q",
+				unit.Resource,
+			)
+
+			if v.repair {
+				unit.Resource = ResourceTypeNone
+			}
+		}
+
+		if !v.validProcedure(path, unit) && v.repair {
+			v.resetUnit(unit)
+		}
+	}
+
+	if v.bj.UnitsIdentifier <= maxID {
+		v.report(
+			[]string{"units_identifier"},
+			"identifier %d is not greater than largest unit id %d",
+			v.bj.UnitsIdentifier,
+			maxID,
+		)
+
+		if v.repair {
+			v.bj.UnitsIdentifier = maxID + 1
+		}
+	}
+}
+
+func (v *validator) validatePiles() {
+	maxID := -1
+
+	for _, id := range SortedKeys(v.bj.Piles) {
+		path := []string{"piles", strconv.Itoa(id)}
+		pile := v.bj.Piles[id]
+
+		if pile == nil {
+			v.report(path, "pile is empty")
+
+			if v.repair {
+				delete(v.bj.Piles, id)
+			}
+
+			continue
+		}
+
+		if pile.ID != id {
+			v.report(append(path, "id"), "id %d does not match key", pile.ID)
+
+			if v.repair {
+				pile.ID = id
+			}
+		}
+
+		if id > maxID {
+			maxID = id
+		}
+
+		if v.bj.Nodes[pile.NodeID] == nil {
+			v.report(
+				append(path, "node"),
+				"node %d does not exist",
+				pile.NodeID,
+			)
+
+			if v.repair {
+				delete(v.bj.Piles, id)
+			}
+
+			continue
+		}
+
+		if v.conf.Resources[pile.Resource] == nil {
+			v.report(
+				append(path, "resource"),
+				"unknown resource type %q",
+				pile.Resource,
+			)
+
+			if v.repair {
+				delete(v.bj.Piles, id)
+			}
+		}
+	}
+
+	if v.bj.PilesIdentifier <= maxID {
+		v.report(
+			[]string{"piles_identifier"},
+			"identifier %d is not greater than largest pile id %d",
+			v.bj.PilesIdentifier,
+			maxID,
+		)
+
+		if v.repair {
+			v.bj.PilesIdentifier = maxID + 1
+		}
+	}
+}
+
+// validProcedure reports problems in a unit's procedure and traversal state
+// and returns whether they can be kept.
+func (v *validator) validProcedure(path []string, unit *UnitJSON) bool {
+	if len(unit.Procedure) == 0 {
+		v.report(append(path, "procedure"), "procedure is empty")
+		return false
+	}
+
+	for i, step := range unit.Procedure {
+		stepPath := append(path, "procedure", strconv.Itoa(i))
+
+		if step == nil {
+			v.report(stepPath, "procedure step is empty")
+			return false
+		}
+
+		if step.StepType == TraverseTo && v.bj.Nodes[step.NodeID] == nil {
+			v.report(
+				append(stepPath, "node_id"),
+				"node %d does not exist",
+				step.NodeID,
+			)
+
+			return false
+		}
+
+		if step.StepType == DoJob && unit.Job == nil {
+			v.report(append(stepPath, "step_type"), "doing job without a job")
+			return false
+		}
+	}
+
+	step := unit.TraversingStep
+	if step < 0 || step > 0 && step >= len(unit.TraversingPath) {
+		v.report(
+			append(path, "traversing_step"),
+			"step %d is out of a path of %d nodes",
+			step,
+			len(unit.TraversingPath),
+		)
+
+		return false
+	}
+
+	for i, id := range unit.TraversingPath {
+		if v.bj.Nodes[id] == nil {
+			v.report(
+				append(path, "traversing_path", strconv.Itoa(i)),
+				"node %d does not exist",
+				id,
+			)
+
+			return false
+		}
+
+		if i <= unit.TraversingStep {
+			continue
+		}
+
+		prevID := unit.TraversingPath[i-1]
+		if !v.connected[NewConnectionIDs(prevID, id)] {
+			v.report(
+				append(path, "traversing_path", strconv.Itoa(i)),
+				"node %d is not connected to node %d",
+				id,
+				prevID,
+			)
+
+			return false
+		}
+	}
+
+	conn := unit.TraversingConnection
+	if conn != nil {
+		connPath := append(path, "traversing_connection")
+
+		if !v.connected[conn.Nodes] {
+			v.report(
+				connPath,
+				"connection %d-%d does not exist",
+				conn.Nodes.Node1,
+				conn.Nodes.Node2,
+			)
+
+			return false
+		}
+
+		if conn.Nodes.Node1 != unit.NodeID && conn.Nodes.Node2 != unit.NodeID {
+			v.report(
+				connPath,
+				"connection %d-%d does not touch node %d",
+				conn.Nodes.Node1,
+				conn.Nodes.Node2,
+				unit.NodeID,
+			)
+
+			return false
+		}
+	}
+
+	if unit.Procedure[0].StepType != Traverse {
+		if conn != nil {
+			v.report(
+				append(path, "traversing_connection"),
+				"on a connection without traversing",
+			)
+
+			return false
+		}
+
+		return true
+	}
+
+	if conn == nil {
+		// waiting at the node to enter a full connection
+		if step < len(unit.TraversingPath) {
+			next := unit.TraversingPath[step]
+			if v.connected[NewConnectionIDs(unit.NodeID, next)] {
+				return true
+			}
+		}
+
+		v.report(
+			append(path, "traversing_connection"),
+			"traversing without a connection",
+		)
+
+		return false
+	}
+
+	if step >= len(unit.TraversingPath) ||
+		unit.TraversingPath[step] != conn.Nodes.Opposite(unit.NodeID) {
+		v.report(
+			append(path, "traversing_step"),
+			"connection %d-%d does not lead to the next node of the path",
+			conn.Nodes.Node1,
+			conn.Nodes.Node2,
+		)
+
+		return false
+	}
+
+	return true
+}
+
+// resetUnit makes the unit wander from the node it is on, releasing its job.
+// A unit carrying a resource delivers it first.
+func (v *validator) resetUnit(unit *UnitJSON) {
+	unit.Procedure = []*ProcedureStepJSON{{StepType: Wander}}
+	if unit.Resource != ResourceTypeNone &&
+		v.conf.Resources[unit.Resource] != nil {
+		unit.Procedure = []*ProcedureStepJSON{
+			{StepType: FindConsumer},
+			{StepType: DropResource},
+		}
+	}
+	unit.TraversingPath = nil
+	unit.TraversingConnection = nil
+	unit.TraversingStep = 0
+	unit.TraversingProgress = 0
+
+	if unit.Job == nil {
+		return
+	}
+
+	job, ok := v.bj.Jobs.Occupied[unit.Job.ID]
+	if ok {
+		delete(v.bj.Jobs.Occupied, unit.Job.ID)
+
+		if v.bj.Jobs.Available == nil {
+			v.bj.Jobs.Available = make(map[int]*JobJSON)
+		}
+
+		v.bj.Jobs.Available[job.ID] = job
+	}
+
+	unit.Job = nil
+	unit.JobProgress = 0
+}
+
+func (v *validator) validatePriorities(
+	name string,
+	priorities map[ResourceType]map[int][]int,
+) {
+	for _, res := range SortedKeys(priorities) {
+		path := []string{name, string(res)}
+
+		if v.conf.Resources[res] == nil {
+			v.report(path, "unknown resource type %q", res)
+
+			if v.repair {
+				delete(priorities, res)
+			}
+
+			continue
+		}
+
+		for _, priority := range SortedKeys(priorities[res]) {
+			ids := priorities[res][priority]
+			valid := make([]int, 0, len(ids))
+
+			for i, id := range ids {
+				if v.bj.Nodes[id] == nil {
+					v.report(
+						append(path, strconv.Itoa(priority), strconv.Itoa(i)),
+						"node %d does not exist",
+						id,
+					)
+
+					continue
+				}
+
+				valid = append(valid, id)
+			}
+
+			if v.repair {
+				priorities[res][priority] = valid
+			}
+		}
+	}
+}
+
+// Validate reports problems in the config, such as node types referring to
+// job or resource types that are not defined.
+func (conf *BlobConfig) Validate() []*ValidationError {
+	v := &validator{conf: conf}
+	v.validateConfig()
+
+	return v.errs
+}
+
+func (v *validator) validateGraphics(
+	path []string,
+	graphics []*render.Primitive,
+) {
+	for i, prim := range graphics {
+		path := append(path, strconv.Itoa(i))
+
+		switch {
+		case prim == nil:
+			v.report(path, "primitive is empty")
+		case !prim.Type.Valid():
+			v.report(path, "unknown primitive type %q", prim.Type)
+		}
+	}
+}
+
+func (v *validator) validateConfig() {
+	if v.conf.Unit == nil {
+		v.report([]string{"unit"}, "unit config is missing")
+	} else if v.conf.Unit.Overflow != nil {
+		v.validateOverflow([]string{"unit", "overflow"}, v.conf.Unit.Overflow)
+	}
+
+	for _, nodeType := range SortedKeys(v.conf.Nodes) {
+		path := []string{"nodes", string(nodeType)}
+		node := v.conf.Nodes[nodeType]
+
+		if node == nil {
+			v.report(path, "node config is empty")
+			continue
+		}
+
+		v.validateResources(append(path, "consumes"), node.Consumes)
+		v.validateResources(append(path, "produces"), node.Produces)
+
+		for i, jobType := range node.Jobs {
+			if v.conf.Jobs[jobType] == nil {
+				v.report(
+					append(path, "jobs", strconv.Itoa(i)),
+					"unknown job type %q",
+					jobType,
+				)
+			}
+		}
+
+		for i, recipeType := range node.Recipes {
+			if v.conf.Recipes[recipeType] == nil {
+				v.report(
+					append(path, "recipes", strconv.Itoa(i)),
+					"unknown recipe %q",
+					recipeType,
+				)
+			}
+		}
+
+		v.validateGraphics(append(path, "graphics"), node.Graphics)
+
+		if len(node.Recipes) > 0 && node.ProductionSpeed <= 0 {
+			v.report(
+				append(path, "production_speed"),
+				"production speed must be positive for a node with recipes",
+			)
+		}
+	}
+
+	for _, connType := range SortedKeys(v.conf.Connections) {
+		path := []string{"connections", string(connType)}
+		conn := v.conf.Connections[connType]
+
+		if conn == nil {
+			v.report(path, "connection config is empty")
+			continue
+		}
+
+		if conn.SpeedMultiplier <= 0 {
+			v.report(
+				append(path, "speed_multiplier"),
+				"speed multiplier must be positive",
+			)
+		}
+
+		if conn.Capacity < 0 {
+			v.report(append(path, "capacity"), "capacity must not be negative")
+		}
+
+		v.validateResources(append(path, "build_cost"), conn.BuildCost)
+	}
+
+	if len(v.conf.Connections) > 0 &&
+		v.conf.Connections[v.conf.DefaultConnection] == nil {
+		v.report(
+			[]string{"default_connection"},
+			"unknown connection type %q",
+			v.conf.DefaultConnection,
+		)
+	}
+
+	for _, res := range SortedKeys(v.conf.Resources) {
+		if v.conf.Resources[res] == nil {
+			continue
+		}
+
+		v.validateGraphics(
+			[]string{"resources", string(res), "graphics"},
+			v.conf.Resources[res].Graphics,
+		)
+	}
+
+	for _, jobType := range SortedKeys(v.conf.Jobs) {
+		path := []string{"jobs", string(jobType)}
+		job := v.conf.Jobs[jobType]
+
+		if job == nil {
+			v.report(path, "job config is empty")
+			continue
+		}
+
+		if v.conf.Recipes[job.Recipe] == nil {
+			v.report(
+				append(path, "recipe"),
+				"unknown recipe %q",
+				job.Recipe,
+			)
+		}
+	}
+
+	for _, recipeType := range SortedKeys(v.conf.Recipes) {
+		path := []string{"recipes", string(recipeType)}
+		recipe := v.conf.Recipes[recipeType]
+
+		if recipe == nil {
+			v.report(path, "recipe config is empty")
+			continue
+		}
+
+		v.validateResources(append(path, "inputs"), recipe.Inputs)
+		v.validateResources(append(path, "outputs"), recipe.Outputs)
+
+		if recipe.Duration <= 0 {
+			v.report(append(path, "duration"), "duration must be positive")
+		}
+	}
+
+	if v.conf.Starter == nil {
+		return
+	}
+
+	for i, node := range v.conf.Starter.Nodes {
+		if v.conf.Nodes[node.NodeType] == nil {
+			v.report(
+				[]string{"starter", "nodes", strconv.Itoa(i), "node_type"},
+				"unknown node type %q",
+				node.NodeType,
+			)
+		}
+	}
+
+	for i, conn := range v.conf.Starter.Connections {
+		for _, index := range conn {
+			if index < 0 || index >= len(v.conf.Starter.Nodes) {
+				v.report(
+					[]string{"starter", "connections", strconv.Itoa(i)},
+					"node index %d out of range",
+					index,
+				)
+			}
+		}
+	}
+}
+
+func (v *validator) validateOverflow(path []string, conf *OverflowConfig) {
+	if !conf.Policy.Valid() {
+		v.report(
+			append(path, "policy"),
+			"unknown overflow policy %q",
+			conf.Policy,
+		)
+	}
+
+	if conf.Policy != OverflowCarry {
+		return
+	}
+
+	if conf.RetryTicks <= 0 {
+		v.report(append(path, "retry_ticks"), "retry ticks must be positive")
+	}
+
+	if conf.MaxRetries < 0 || conf.MaxRetries > maxOverflowRetries {
+		v.report(
+			append(path, "max_retries"),
+			"max retries must be between 0 and %d",
+			maxOverflowRetries,
+		)
+	}
+}
+
+func (v *validator) validateResources(
+	path []string,
+	resources map[ResourceType]int,
+) {
+	for _, res := range SortedKeys(resources) {
+		if v.conf.Resources[res] == nil {
+			v.report(
+				append(path, string(res)),
+				"unknown resource type %q",
+				res,
+			)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// traversing puts unit 0 of a line blob on its way from node 0 to node 2.
+func traversing(bj *BlobJSON) *UnitJSON {
+	unit := bj.Units[0]
+	unit.Procedure = []*ProcedureStepJSON{{StepType: Traverse}}
+	unit.TraversingPath = []int{1, 2}
+	unit.TraversingConnection = &Connection{
+		Nodes:  NewConnectionIDs(0, 1),
+		Length: 60,
+	}
+
+	return unit
+}
+
+func TestValidateAndRepair(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(bj *BlobJSON)
+		paths  []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(bj *BlobJSON) {},
+		},
+		{
+			name:   "valid traversal",
+			mutate: func(bj *BlobJSON) { traversing(bj) },
+		},
+		{
+			name:   "empty node",
+			mutate: func(bj *BlobJSON) { bj.Nodes[5] = nil },
+			paths:  []string{"nodes.5"},
+		},
+		{
+			name: "unknown node type",
+			mutate: func(bj *BlobJSON) {
+				bj.Nodes[3] = &NodeJSON{ID: 3, NodeType: "mine"}
+				bj.NodesIdentifier = 4
+			},
+			paths: []string{"nodes.3.node_type"},
+		},
+		{
+			name:   "node id",
+			mutate: func(bj *BlobJSON) { bj.Nodes[1].ID = 7 },
+			paths:  []string{"nodes.1.id"},
+		},
+		{
+			name:   "unknown recipe",
+			mutate: func(bj *BlobJSON) { bj.Nodes[0].Recipe = "bake" },
+			paths:  []string{"nodes.0.recipe"},
+		},
+		{
+			name: "unknown stock",
+			mutate: func(bj *BlobJSON) {
+				bj.Nodes[0].Resources = map[ResourceType][]pixel.Vec{
+					"gold": {pixel.ZV},
+				}
+			},
+			paths: []string{"nodes.0.resources.gold"},
+		},
+		{
+			name:   "nodes identifier",
+			mutate: func(bj *BlobJSON) { bj.NodesIdentifier = 2 },
+			paths:  []string{"nodes_identifier"},
+		},
+		{
+			name: "connection to missing node",
+			mutate: func(bj *BlobJSON) {
+				bj.Connections = append(
+					bj.Connections,
+					&Connection{Nodes: NewConnectionIDs(1, 7)},
+				)
+			},
+			paths: []string{"connections.2.nodes.node_2"},
+		},
+		{
+			name: "node connected to itself",
+			mutate: func(bj *BlobJSON) {
+				bj.Connections = append(
+					bj.Connections,
+					&Connection{Nodes: ConnectionIDs{1, 1}},
+				)
+			},
+			paths: []string{"connections.2.nodes"},
+		},
+		{
+			name: "duplicate connection",
+			mutate: func(bj *BlobJSON) {
+				bj.Connections = append(
+					bj.Connections,
+					&Connection{Nodes: ConnectionIDs{1, 0}, Length: 60},
+				)
+			},
+			paths: []string{"connections.2.nodes", "connections.2"},
+		},
+		{
+			name:   "unknown connection type",
+			mutate: func(bj *BlobJSON) { bj.Connections[0].Type = "rail" },
+			paths:  []string{"connections.0.type"},
+		},
+		{
+			name: "unknown job type",
+			mutate: func(bj *BlobJSON) {
+				bj.Jobs.Available[0].JobType = "mining"
+			},
+			paths: []string{"jobs.available.0.job_type"},
+		},
+		{
+			name: "job in two queues",
+			mutate: func(bj *BlobJSON) {
+				job := *bj.Jobs.Available[0]
+				bj.Jobs.Halted = map[int]*JobJSON{0: &job}
+			},
+			paths: []string{"jobs.halted.0"},
+		},
+		{
+			name:   "jobs identifier",
+			mutate: func(bj *BlobJSON) { bj.JobsIdentifier = 1 },
+			paths:  []string{"jobs_identifier"},
+		},
+		{
+			name:   "unit on missing node",
+			mutate: func(bj *BlobJSON) { bj.Units[1].NodeID = 7 },
+			paths:  []string{"units.1.node"},
+		},
+		{
+			name:   "unknown carried resource",
+			mutate: func(bj *BlobJSON) { bj.Units[0].Resource = "gold" },
+			paths:  []string{"units.0.resource"},
+		},
+		{
+			name:   "empty procedure",
+			mutate: func(bj *BlobJSON) { bj.Units[0].Procedure = nil },
+			paths:  []string{"units.0.procedure"},
+		},
+		{
+			name: "traversing to missing node",
+			mutate: func(bj *BlobJSON) {
+				bj.Units[0].Procedure = []*ProcedureStepJSON{
+					{StepType: TraverseTo, NodeID: 7},
+				}
+			},
+			paths: []string{"units.0.procedure.0.node_id"},
+		},
+		{
+			name: "doing job without a job",
+			mutate: func(bj *BlobJSON) {
+				bj.Units[0].Procedure = []*ProcedureStepJSON{{StepType: DoJob}}
+			},
+			paths: []string{"units.0.procedure.0.step_type"},
+		},
+		{
+			name: "step past the path",
+			mutate: func(bj *BlobJSON) {
+				unit := traversing(bj)
+				unit.TraversingConnection = nil
+				unit.TraversingStep = 2
+			},
+			paths: []string{"units.0.traversing_step"},
+		},
+		{
+			name:   "negative step",
+			mutate: func(bj *BlobJSON) { traversing(bj).TraversingStep = -1 },
+			paths:  []string{"units.0.traversing_step"},
+		},
+		{
+			name: "step without a path",
+			mutate: func(bj *BlobJSON) {
+				unit := traversing(bj)
+				unit.TraversingPath = nil
+				unit.TraversingStep = 1
+			},
+			paths: []string{"units.0.traversing_step"},
+		},
+		{
+			name: "path through unconnected nodes",
+			mutate: func(bj *BlobJSON) {
+				traversing(bj).TraversingPath = []int{1, 2, 0}
+			},
+			paths: []string{"units.0.traversing_path.2"},
+		},
+		{
+			name: "missing connection",
+			mutate: func(bj *BlobJSON) {
+				conn := traversing(bj).TraversingConnection
+				conn.Nodes = NewConnectionIDs(0, 2)
+			},
+			paths: []string{"units.0.traversing_connection"},
+		},
+		{
+			name: "connection away from the node",
+			mutate: func(bj *BlobJSON) {
+				conn := traversing(bj).TraversingConnection
+				conn.Nodes = NewConnectionIDs(1, 2)
+			},
+			paths: []string{"units.0.traversing_connection"},
+		},
+		{
+			name: "connection off the path",
+			mutate: func(bj *BlobJSON) {
+				traversing(bj).TraversingPath = []int{2}
+			},
+			paths: []string{"units.0.traversing_step"},
+		},
+		{
+			name: "waiting for a missing connection",
+			mutate: func(bj *BlobJSON) {
+				unit := traversing(bj)
+				unit.TraversingPath = []int{2}
+				unit.TraversingConnection = nil
+			},
+			paths: []string{"units.0.traversing_connection"},
+		},
+		{
+			name: "connection without traversing",
+			mutate: func(bj *BlobJSON) {
+				traversing(bj).Procedure[0].StepType = Wander
+			},
+			paths: []string{"units.0.traversing_connection"},
+		},
+		{
+			name:   "units identifier",
+			mutate: func(bj *BlobJSON) { bj.UnitsIdentifier = 2 },
+			paths:  []string{"units_identifier"},
+		},
+		{
+			name: "pile on missing node",
+			mutate: func(bj *BlobJSON) {
+				bj.Piles = map[int]*PileJSON{
+					0: {NodeID: 7, Resource: "moss"},
+				}
+				bj.PilesIdentifier = 1
+			},
+			paths: []string{"piles.0.node"},
+		},
+		{
+			name: "unknown priority resource",
+			mutate: func(bj *BlobJSON) {
+				bj.Consumers["gold"] = map[int][]int{0: {1}}
+			},
+			paths: []string{"consumers.gold"},
+		},
+		{
+			name: "missing producer",
+			mutate: func(bj *BlobJSON) {
+				bj.Producers["moss"][0] = append(bj.Producers["moss"][0], 7)
+			},
+			paths: []string{"producers.moss.0.2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bj := lineBlob(3).ToJSON()
+			test.mutate(bj)
+
+			errs := bj.Validate(graphConf)
+			if len(errs) != len(test.paths) {
+				t.Fatalf("errors %v, expected %v", errs, test.paths)
+			}
+
+			for i, err := range errs {
+				if err.Path != test.paths[i] {
+					t.Fatalf("errors %v, expected %v", errs, test.paths)
+				}
+			}
+
+			if fixed := bj.Repair(graphConf); len(fixed) != len(errs) {
+				t.Fatalf("repaired %v, expected %v", fixed, errs)
+			}
+
+			if errs := bj.Validate(graphConf); len(errs) > 0 {
+				t.Fatalf("invalid after repair: %v", errs)
+			}
+
+			b := NewBlob(bj, graphConf)
+			checkGraph(t, b)
+			run(t, b, 200)
+		})
+	}
+}
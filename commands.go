@@ -0,0 +1,420 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"private/grow/blob"
+	"private/grow/config"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/pkg/errors"
+)
+
+const usage = `usage: grow <command> [flags]
+
+commands:
+  play      play a saved game (default)
+  new       start a new game from the starter layout
+  validate  check a save for problems
+  sim       run the simulation without a window
+
+run "grow <command> -h" for the flags of a command.
+`
+
+func runCommand(args []string) error {
+	if len(args) == 0 {
+		return playCommand(nil)
+	}
+
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "play":
+		return playCommand(args)
+	case "new":
+		return newCommand(args)
+	case "validate":
+		return validateCommand(args)
+	case "sim":
+		return simCommand(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+
+	// flags without a command play
+	if len(command) > 0 && command[0] == '-' {
+		return playCommand(append([]string{command}, args...))
+	}
+
+	fmt.Fprint(os.Stderr, usage)
+
+	return fmt.Errorf("unknown command %q", command)
+}
+
+// windowFlags registers flags shared by commands that open a window.
+func windowFlags(fs *flag.FlagSet) (*int, *string) {
+	fps := fs.Int("fps", 60, "frame rate limit")
+	window := fs.String("window", "800x800", "window size as WxH")
+
+	return fps, window
+}
+
+func parseWindow(window string) (pixel.Rect, error) {
+	var w, h int
+
+	_, err := fmt.Sscanf(window, "%dx%d", &w, &h)
+	if err != nil || w <= 0 || h <= 0 {
+		return pixel.Rect{}, fmt.Errorf("invalid window size %q", window)
+	}
+
+	return pixel.R(0, 0, float64(w), float64(h)), nil
+}
+
+// loadSave loads and validates a save. Problems are printed, an invalid save
+// is an error unless repair is set.
+func loadSave(
+	conf *config.Config,
+	savePath string,
+	repair bool,
+) (*config.Save, error) {
+	save, err := config.LoadSave(savePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load save %s", savePath)
+	}
+
+	if save.Blob == nil {
+		save.Blob = &blob.BlobJSON{}
+	}
+
+	problems := save.Blob.Validate(&conf.Blob)
+	if len(problems) == 0 {
+		return save, nil
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, "invalid save:", problem)
+	}
+
+	if !repair {
+		return nil, fmt.Errorf(
+			"save %s has %d problems, use -repair to fix them",
+			savePath,
+			len(problems),
+		)
+	}
+
+	save.Blob.Repair(&conf.Blob)
+
+	return save, nil
+}
+
+// newSave returns the save of a new game. A seed of 0 picks a random one.
+func newSave(conf *config.Config, seed int64) (*config.Save, error) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	bj, err := blob.NewBlobJSON(&conf.Blob, seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create new game")
+	}
+
+	return &config.Save{Blob: bj}, nil
+}
+
+// loadOrNewSave loads the save, or starts a new game if it does not exist.
+func loadOrNewSave(
+	conf *config.Config,
+	savePath string,
+	repair bool,
+) (*config.Save, error) {
+	_, err := os.Stat(savePath)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "no save at %s, starting a new game\n", savePath)
+		return newSave(conf, 0)
+	}
+
+	return loadSave(conf, savePath, repair)
+}
+
+// logEvents appends the blob's events to the file at path, if it is set. The
+// returned functions write the buffered events, to be called after every
+// tick, and close the log.
+func logEvents(b *blob.Blob, path string) (func(), func() error, error) {
+	if path == "" {
+		return func() {}, func() error { return nil }, nil
+	}
+
+	log, err := config.NewEventLog(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unsubscribe := b.Subscribe(log.Handle)
+
+	return log.Flush, func() error {
+		unsubscribe()
+		return log.Close()
+	}, nil
+}
+
+// runWindow runs play on the main thread, as required by pixelgl.
+func runWindow(opts *playOptions) error {
+	var err error
+
+	pixelgl.Run(func() {
+		err = play(opts)
+	})
+
+	return err
+}
+
+func playCommand(args []string) error {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "config file")
+	savePath := fs.String("save", "save.json", "save file")
+	autosave := fs.Int(
+		"autosave",
+		0,
+		"load the n-th newest autosave instead of the save file",
+	)
+	repair := fs.Bool(
+		"repair",
+		false,
+		"drop or fix invalid references in the save",
+	)
+	fps, window := windowFlags(fs)
+
+	fs.Parse(args)
+
+	if *fps <= 0 {
+		return errors.New("-fps must be positive")
+	}
+
+	bounds, err := parseWindow(*window)
+	if err != nil {
+		return err
+	}
+
+	conf, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config %s", *configPath)
+	}
+
+	loadPath := *savePath
+	if *autosave > 0 {
+		loadPath = config.AutosavePath(&conf.Autosave, *autosave)
+	}
+
+	var save *config.Save
+
+	if *autosave > 0 {
+		save, err = loadSave(conf, loadPath, *repair)
+	} else {
+		save, err = loadOrNewSave(conf, loadPath, *repair)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return runWindow(&playOptions{
+		conf:     conf,
+		save:     save,
+		savePath: *savePath,
+		fps:      *fps,
+		window:   bounds,
+	})
+}
+
+func newCommand(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "config file")
+	savePath := fs.String("save", "save.json", "save file to create")
+	force := fs.Bool("force", false, "overwrite an existing save file")
+	seed := fs.Int64("seed", 0, "simulation seed, 0 picks a random one")
+	fps, window := windowFlags(fs)
+
+	fs.Parse(args)
+
+	if *fps <= 0 {
+		return errors.New("-fps must be positive")
+	}
+
+	bounds, err := parseWindow(*window)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(*savePath)
+	if err == nil && !*force {
+		return fmt.Errorf(
+			"save %s already exists, use -force to overwrite it",
+			*savePath,
+		)
+	}
+
+	conf, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config %s", *configPath)
+	}
+
+	save, err := newSave(conf, *seed)
+	if err != nil {
+		return err
+	}
+
+	return runWindow(&playOptions{
+		conf:     conf,
+		save:     save,
+		savePath: *savePath,
+		fps:      *fps,
+		window:   bounds,
+	})
+}
+
+func validateCommand(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "config file")
+	repair := fs.Bool(
+		"repair",
+		false,
+		"fix the problems and record the repaired save",
+	)
+	out := fs.String("out", "", "where to record the repaired save")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: grow validate [flags] <save>")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single save file")
+	}
+
+	savePath := fs.Arg(0)
+
+	conf, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config %s", *configPath)
+	}
+
+	save, err := loadSave(conf, savePath, *repair)
+	if err != nil {
+		return err
+	}
+
+	if !*repair {
+		fmt.Println(savePath, "is valid")
+		return nil
+	}
+
+	outPath := *out
+	if outPath == "" {
+		outPath = savePath
+	}
+
+	err = config.RecordSave(outPath, save)
+	if err != nil {
+		return errors.Wrap(err, "failed to record repaired save")
+	}
+
+	return nil
+}
+
+func simCommand(args []string) error {
+	fs := flag.NewFlagSet("sim", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "config file")
+	savePath := fs.String("save", "save.json", "save file to start from")
+	ticks := fs.Int("ticks", 0, "number of ticks to simulate")
+	out := fs.String("out", "", "where to record the resulting save")
+	events := fs.String(
+		"events",
+		"",
+		"JSONL file to append events to, instead of the config's",
+	)
+	statsPath := fs.String(
+		"stats",
+		"",
+		"CSV or JSON file to export stats to, instead of the config's",
+	)
+	repair := fs.Bool(
+		"repair",
+		false,
+		"drop or fix invalid references in the save",
+	)
+
+	fs.Parse(args)
+
+	if *ticks <= 0 {
+		return errors.New("-ticks must be positive")
+	}
+
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+
+	conf, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config %s", *configPath)
+	}
+
+	save, err := loadOrNewSave(conf, *savePath, *repair)
+	if err != nil {
+		return err
+	}
+
+	b := blob.NewBlob(save.Blob, &conf.Blob)
+
+	eventsPath := conf.Events.Path
+	if *events != "" {
+		eventsPath = *events
+	}
+
+	flushEvents, closeEvents, err := logEvents(b, eventsPath)
+	if err != nil {
+		return err
+	}
+
+	stats := blob.NewStats(b, conf.Stats.Interval, conf.Stats.Samples)
+
+	for i := 0; i < *ticks; i++ {
+		b.Update()
+		flushEvents()
+		stats.Update()
+	}
+
+	err = closeEvents()
+	if err != nil {
+		return err
+	}
+
+	export := conf.Stats.Export
+	if *statsPath != "" {
+		export = *statsPath
+	}
+
+	if export != "" {
+		err = config.ExportStats(export, stats)
+		if err != nil {
+			return err
+		}
+	}
+
+	save.Blob = b.ToJSON()
+
+	err = config.RecordSave(*out, save)
+	if err != nil {
+		return errors.Wrap(err, "failed to record save")
+	}
+
+	return nil
+}
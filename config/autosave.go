@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+type AutosaveConfig struct {
+	Interval int    `json:"interval"` // ticks between autosaves, 0 disables
+	Keep     int    `json:"keep"`     // number of rotated autosaves to keep
+	Dir      string `json:"dir"`
+}
+
+// Autosaver records saves every few simulation ticks, rotating them so that
+// the last Keep autosaves are kept. Autosave 1 is always the newest one.
+type Autosaver struct {
+	conf *AutosaveConfig
+}
+
+func NewAutosaver(conf *AutosaveConfig) *Autosaver {
+	return &Autosaver{conf: conf}
+}
+
+// Due returns whether an autosave should be recorded at the given tick.
+func (a *Autosaver) Due(tick int) bool {
+	return a.conf.Interval > 0 && tick > 0 && tick%a.conf.Interval == 0
+}
+
+func (a *Autosaver) Record(save *Save) error {
+	err := os.MkdirAll(a.conf.Dir, 0o755)
+	if err != nil {
+		return errors.Wrap(err, "failed to create autosave directory")
+	}
+
+	keep := a.conf.Keep
+	if keep < 1 {
+		keep = 1
+	}
+
+	for n := keep - 1; n >= 1; n-- {
+		err = os.Rename(AutosavePath(a.conf, n), AutosavePath(a.conf, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to rotate autosaves")
+		}
+	}
+
+	return RecordSave(AutosavePath(a.conf, 1), save)
+}
+
+// AutosavePath returns path of the n-th newest autosave, starting from 1.
+func AutosavePath(conf *AutosaveConfig, n int) string {
+	return filepath.Join(conf.Dir, fmt.Sprintf("autosave.%d.json", n))
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"private/grow/blob"
+)
+
+func TestAutosaveDue(t *testing.T) {
+	tests := []struct {
+		interval int
+		tick     int
+		due      bool
+	}{
+		{0, 0, false},
+		{0, 100, false},
+		{10, 0, false},
+		{10, 5, false},
+		{10, 10, true},
+		{10, 30, true},
+		{10, 31, false},
+	}
+
+	for _, test := range tests {
+		a := NewAutosaver(&AutosaveConfig{Interval: test.interval})
+		if due := a.Due(test.tick); due != test.due {
+			t.Errorf(
+				"interval %d, tick %d: due %v, expected %v",
+				test.interval,
+				test.tick,
+				due,
+				test.due,
+			)
+		}
+	}
+}
+
+func TestAutosaveRotation(t *testing.T) {
+	conf := &AutosaveConfig{
+		Interval: 1,
+		Keep:     3,
+		Dir:      filepath.Join(t.TempDir(), "autosaves"),
+	}
+	a := NewAutosaver(conf)
+
+	for tick := 1; tick <= 5; tick++ {
+		err := a.Record(&Save{Blob: &blob.BlobJSON{Tick: tick}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// -autosave n loads AutosavePath(conf, n)
+	for n := 1; n <= 3; n++ {
+		save, err := LoadSave(AutosavePath(conf, n))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if save.Blob.Tick != 6-n {
+			t.Errorf(
+				"autosave %d is of tick %d, expected %d",
+				n,
+				save.Blob.Tick,
+				6-n,
+			)
+		}
+	}
+
+	entries, err := os.ReadDir(conf.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("%d autosaves kept, expected 3", len(entries))
+	}
+}
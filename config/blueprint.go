@@ -0,0 +1,116 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"private/grow/blob"
+
+	"github.com/pkg/errors"
+)
+
+type BlueprintsConfig struct {
+	Dir string `json:"dir"`
+}
+
+// BlueprintLibrary stores blueprints as JSON files in a directory, one file
+// per blueprint named after it.
+type BlueprintLibrary struct {
+	conf *BlueprintsConfig
+}
+
+func NewBlueprintLibrary(conf *BlueprintsConfig) *BlueprintLibrary {
+	return &BlueprintLibrary{conf: conf}
+}
+
+// Names returns the names of the stored blueprints in alphabetical order.
+func (l *BlueprintLibrary) Names() ([]string, error) {
+	entries, err := os.ReadDir(l.conf.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read blueprint directory")
+	}
+
+	var names []string
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") ||
+			filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		names = append(names, strings.TrimSuffix(name, ".json"))
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (l *BlueprintLibrary) Load(name string) (*blob.Blueprint, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bp := &blob.Blueprint{}
+
+	err = json.Unmarshal(data, bp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid blueprint %q", name)
+	}
+
+	bp.Name = name
+
+	return bp, nil
+}
+
+// Save stores the blueprint under its name, replacing a blueprint of the same
+// name.
+func (l *BlueprintLibrary) Save(bp *blob.Blueprint) error {
+	path, err := l.path(bp.Name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(l.conf.Dir, 0o755)
+	if err != nil {
+		return errors.Wrap(err, "failed to create blueprint directory")
+	}
+
+	return writeJSON(path, bp)
+}
+
+// path returns the file of a blueprint. Names are limited to letters, digits,
+// spaces, dashes and underscores, so that they can't point outside of the
+// directory.
+func (l *BlueprintLibrary) path(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", errors.New("blueprint name is empty")
+	}
+
+	for _, r := range name {
+		if !isNameRune(r) {
+			return "", errors.Errorf("invalid blueprint name %q", name)
+		}
+	}
+
+	return filepath.Join(l.conf.Dir, name+".json"), nil
+}
+
+func isNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+		r >= '0' && r <= '9' || r == ' ' || r == '-' || r == '_'
+}
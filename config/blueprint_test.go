@@ -0,0 +1,99 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"private/grow/blob"
+)
+
+func TestBlueprintPath(t *testing.T) {
+	l := NewBlueprintLibrary(&BlueprintsConfig{Dir: "blueprints"})
+
+	tests := []struct {
+		name string
+		path string // "" if the name is rejected
+	}{
+		{"farm ring", filepath.Join("blueprints", "farm ring.json")},
+		{"big_farm-2", filepath.Join("blueprints", "big_farm-2.json")},
+		{"", ""},
+		{"   ", ""},
+		{"../x", ""},
+		{"..", ""},
+		{"a/b", ""},
+		{`a\b`, ""},
+		{"/etc/passwd", ""},
+		{".hidden", ""},
+		{"farm.json", ""},
+		{"ferme brûlée", ""},
+		{"農場", ""},
+		{"farm\n", ""},
+	}
+
+	for _, test := range tests {
+		path, err := l.path(test.name)
+
+		if test.path == "" {
+			if err == nil {
+				t.Errorf("name %q: expected an error, got %q", test.name, path)
+			}
+
+			continue
+		}
+
+		if err != nil || path != test.path {
+			t.Errorf(
+				"name %q: got %q, %v, expected %q",
+				test.name,
+				path,
+				err,
+				test.path,
+			)
+		}
+	}
+}
+
+func TestBlueprintLibrary(t *testing.T) {
+	l := NewBlueprintLibrary(&BlueprintsConfig{
+		Dir: filepath.Join(t.TempDir(), "blueprints"),
+	})
+
+	names, err := l.Names()
+	if err != nil || len(names) != 0 {
+		t.Fatalf("got %v, %v before saving, expected nothing", names, err)
+	}
+
+	for _, name := range []string{"ring", "line"} {
+		err := l.Save(&blob.Blueprint{
+			Name:  name,
+			Nodes: []*blob.BlueprintNode{{ID: 1, NodeType: "farm"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = l.Save(&blob.Blueprint{Name: "../ring"})
+	if err == nil {
+		t.Fatal("expected an invalid name to be rejected")
+	}
+
+	names, err = l.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 2 || names[0] != "line" || names[1] != "ring" {
+		t.Fatalf("names %v, expected [line ring]", names)
+	}
+
+	bp, err := l.Load("ring")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bp.Name != "ring" || len(bp.Nodes) != 1 ||
+		bp.Nodes[0].NodeType != "farm" {
+		t.Fatalf("loaded %+v", bp)
+	}
+}
@@ -2,15 +2,24 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"private/grow/blob"
 	"private/grow/handler"
 )
 
 type Config struct {
-	View handler.ViewConfig `json:"view"`
-	Blob blob.BlobConfig    `json:"blob"`
+	View       handler.ViewConfig   `json:"view"`
+	Clock      handler.ClockConfig  `json:"clock"`
+	Editor     handler.EditorConfig `json:"editor"`
+	Autosave   AutosaveConfig       `json:"autosave"`
+	Blueprints BlueprintsConfig     `json:"blueprints"`
+	Events     EventsConfig         `json:"events"`
+	Stats      StatsConfig          `json:"stats"`
+	Blob       blob.BlobConfig      `json:"blob"`
 }
 
 func LoadConfig(filepath string) (*Config, error) {
@@ -28,14 +37,34 @@ func LoadConfig(filepath string) (*Config, error) {
 		return nil, err
 	}
 
+	var lines []string
+
+	for _, problem := range conf.Stats.Validate() {
+		lines = append(lines, "stats."+problem.Error())
+	}
+
+	for _, problem := range conf.Blob.Validate() {
+		lines = append(lines, "blob."+problem.Error())
+	}
+
+	if len(lines) > 0 {
+		return nil, fmt.Errorf(
+			"invalid config:\n\t%s",
+			strings.Join(lines, "\n\t"),
+		)
+	}
+
 	return conf, nil
 }
 
 type Save struct {
-	View *handler.ViewJSON `json:"view"`
-	Blob *blob.BlobJSON    `json:"blob"`
+	Version int               `json:"version"`
+	View    *handler.ViewJSON `json:"view"`
+	Blob    *blob.BlobJSON    `json:"blob"`
 }
 
+// LoadSave reads a save, migrating it to SaveVersion if it was written by an
+// older version.
 func LoadSave(filepath string) (*Save, error) {
 	f, err := os.Open(filepath)
 	if err != nil {
@@ -44,9 +73,29 @@ func LoadSave(filepath string) (*Save, error) {
 
 	defer f.Close()
 
+	doc := make(map[string]interface{})
+
+	decoder := json.NewDecoder(f)
+	decoder.UseNumber()
+
+	err = decoder.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Migrate(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
 	save := &Save{}
 
-	err = json.NewDecoder(f).Decode(&save)
+	err = json.Unmarshal(data, save)
 	if err != nil {
 		return nil, err
 	}
@@ -54,21 +103,44 @@ func LoadSave(filepath string) (*Save, error) {
 	return save, nil
 }
 
-func RecordSave(filepath string, save *Save) error {
-	f, err := os.Create(filepath)
+// RecordSave writes the save to a temporary file next to path and renames it
+// over path, so that a crash never leaves a partially written save behind.
+func RecordSave(path string, save *Save) error {
+	save.Version = SaveVersion
+
+	return writeJSON(path, save)
+}
+
+// writeJSON atomically writes v as indented JSON to path.
+func writeJSON(path string, v interface{}) error {
+	f, err := os.CreateTemp(
+		filepath.Dir(path),
+		"."+filepath.Base(path)+".*.tmp",
+	)
 	if err != nil {
 		return err
 	}
 
+	defer os.Remove(f.Name()) // no-op once renamed
 	defer f.Close()
 
 	encoder := json.NewEncoder(f)
 	encoder.SetIndent("", "    ")
 
-	err = encoder.Encode(save)
+	err = encoder.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = f.Close()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return os.Rename(f.Name(), path)
 }
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigNegativeSamples(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	err := os.WriteFile(
+		path,
+		[]byte(`{"stats": {"samples": -1}, "blob": {"unit": {}}}`),
+		0o644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "stats.samples") {
+		t.Fatalf("expected stats.samples to be rejected, got %v", err)
+	}
+}
+
+func TestWriteJSONFailureKeepsOldFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "save.json")
+
+	err := writeJSON(path, map[string]int{"tick": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// channels can't be encoded, the write fails halfway
+	err = writeJSON(path, map[string]interface{}{"tick": make(chan int)})
+	if err == nil {
+		t.Fatal("expected the write to fail")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), `"tick": 1`) {
+		t.Fatalf("old file was changed to %q", data)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected only the old file, found %d files", len(entries))
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"private/grow/blob"
+
+	"github.com/pkg/errors"
+)
+
+type EventsConfig struct {
+	Path string `json:"path"` // JSONL file events are appended to, "" disables
+}
+
+// EventLog appends the blob's events to a file, one JSON object per line.
+// Events are buffered until Flush, which is called after every tick.
+type EventLog struct {
+	f       *os.File
+	w       *bufio.Writer
+	encoder *json.Encoder
+	err     error // first write error, handlers can't return it
+}
+
+type eventLine struct {
+	Tick  int            `json:"tick"`
+	Type  blob.EventType `json:"type"`
+	Event blob.Event     `json:"event"`
+}
+
+func NewEventLog(path string) (*EventLog, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create event log directory")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open event log")
+	}
+
+	w := bufio.NewWriter(f)
+
+	return &EventLog{f: f, w: w, encoder: json.NewEncoder(w)}, nil
+}
+
+// Handle writes an event, it is a blob.EventHandler.
+func (l *EventLog) Handle(tick int, event blob.Event) {
+	if l.err != nil {
+		return
+	}
+
+	l.err = l.encoder.Encode(&eventLine{
+		Tick:  tick,
+		Type:  event.Type(),
+		Event: event,
+	})
+}
+
+// Flush writes the buffered events to the file, errors are returned by Close.
+func (l *EventLog) Flush() {
+	if l.err != nil {
+		return
+	}
+
+	l.err = l.w.Flush()
+}
+
+// Close flushes the log and returns the first error writing it.
+func (l *EventLog) Close() error {
+	err := l.w.Flush()
+	if l.err == nil {
+		l.err = err
+	}
+
+	err = l.f.Close()
+	if l.err == nil {
+		l.err = err
+	}
+
+	return errors.Wrap(l.err, "failed to write event log")
+}
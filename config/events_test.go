@@ -0,0 +1,52 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"private/grow/blob"
+)
+
+func TestEventLogFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	log, err := NewEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := func() int {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return bytes.Count(data, []byte("\n"))
+	}
+
+	log.Handle(1, &blob.UnitSpawned{UnitID: 0})
+	log.Handle(1, &blob.UnitSpawned{UnitID: 1})
+
+	if n := lines(); n != 0 {
+		t.Fatalf("%d lines written during the tick, expected 0", n)
+	}
+
+	log.Flush()
+
+	if n := lines(); n != 2 {
+		t.Fatalf("%d lines written after the tick, expected 2", n)
+	}
+
+	log.Handle(2, &blob.UnitSpawned{UnitID: 2})
+
+	err = log.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := lines(); n != 3 {
+		t.Fatalf("%d lines written on close, expected 3", n)
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// SaveVersion is the version of the save format written by RecordSave.
+const SaveVersion = 1
+
+// Migration upgrades a decoded save document from the version it is
+// registered under to the next one.
+type Migration func(doc map[string]interface{}) error
+
+// migrations maps a save version to the migration that upgrades it by one
+// version. Version 0 are saves written before the version field existed.
+var migrations = map[int]Migration{
+	0: migrateV0,
+}
+
+// Migrate upgrades a decoded save document to SaveVersion, applying
+// migrations one version at a time.
+func Migrate(doc map[string]interface{}) error {
+	version, err := documentVersion(doc)
+	if err != nil {
+		return err
+	}
+
+	if version > SaveVersion {
+		return fmt.Errorf(
+			"save version %d is newer than supported version %d",
+			version,
+			SaveVersion,
+		)
+	}
+
+	for ; version < SaveVersion; version++ {
+		migration, ok := migrations[version]
+		if !ok {
+			return fmt.Errorf("no migration from save version %d", version)
+		}
+
+		err = migration(doc)
+		if err != nil {
+			return errors.Wrapf(
+				err,
+				"failed to migrate save from version %d",
+				version,
+			)
+		}
+
+		doc["version"] = version + 1
+	}
+
+	return nil
+}
+
+func documentVersion(doc map[string]interface{}) (int, error) {
+	v, ok := doc["version"]
+	if !ok || v == nil {
+		return 0, nil
+	}
+
+	switch version := v.(type) {
+	case json.Number:
+		n, err := version.Int64()
+		if err != nil {
+			return 0, errors.Wrap(err, "invalid save version")
+		}
+
+		return int(n), nil
+	case float64:
+		return int(version), nil
+	case int:
+		return version, nil
+	}
+
+	return 0, fmt.Errorf("invalid save version %v", v)
+}
+
+// migrateV0 gives units without a procedure a wander step, loading them as
+// is panics on the first update.
+func migrateV0(doc map[string]interface{}) error {
+	b, err := object(doc, "blob")
+	if err != nil || b == nil {
+		return err
+	}
+
+	units, err := object(b, "units")
+	if err != nil {
+		return err
+	}
+
+	for id, u := range units {
+		unit, ok := u.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unit %s is not an object", id)
+		}
+
+		procedure, _ := unit["procedure"].([]interface{})
+		if len(procedure) > 0 {
+			continue
+		}
+
+		unit["procedure"] = []interface{}{
+			map[string]interface{}{"step_type": "wander"},
+		}
+	}
+
+	return nil
+}
+
+// object returns the object stored under key, nil if the key is not set.
+func object(
+	doc map[string]interface{},
+	key string,
+) (map[string]interface{}, error) {
+	v, ok := doc[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", key)
+	}
+
+	return obj, nil
+}
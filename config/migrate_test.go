@@ -0,0 +1,82 @@
+package config
+
+import (
+	"testing"
+
+	"private/grow/blob"
+)
+
+func TestMigrateV0(t *testing.T) {
+	save, err := LoadSave("testdata/save_v0.json")
+	if err != nil {
+		t.Fatalf("failed to load save: %v", err)
+	}
+
+	if save.Version != SaveVersion {
+		t.Errorf("expected version %d, got %d", SaveVersion, save.Version)
+	}
+
+	expected := map[int]blob.ProcedureStepType{
+		0: blob.Wander,
+		1: blob.Wander,
+		2: blob.FindTask,
+	}
+
+	for id, stepType := range expected {
+		unit := save.Blob.Units[id]
+		if unit == nil {
+			t.Fatalf("unit %d missing", id)
+		}
+
+		if len(unit.Procedure) != 1 {
+			t.Fatalf(
+				"unit %d: expected 1 procedure step, got %d",
+				id,
+				len(unit.Procedure),
+			)
+		}
+
+		if unit.Procedure[0].StepType != stepType {
+			t.Errorf(
+				"unit %d: expected step %q, got %q",
+				id,
+				stepType,
+				unit.Procedure[0].StepType,
+			)
+		}
+	}
+
+	if save.Blob.Units[1].Hunger != 20 {
+		t.Errorf("unit 1: hunger not preserved")
+	}
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+	err := Migrate(map[string]interface{}{"version": SaveVersion + 1})
+	if err == nil {
+		t.Fatal("expected error for save newer than supported")
+	}
+}
+
+func TestMigrateCurrentVersion(t *testing.T) {
+	doc := map[string]interface{}{
+		"version": SaveVersion,
+		"blob": map[string]interface{}{
+			"units": map[string]interface{}{
+				"0": map[string]interface{}{"id": 0},
+			},
+		},
+	}
+
+	err := Migrate(doc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	units := doc["blob"].(map[string]interface{})["units"]
+	unit := units.(map[string]interface{})["0"].(map[string]interface{})
+
+	if _, ok := unit["procedure"]; ok {
+		t.Error("current version save must not be migrated")
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"private/grow/blob"
+
+	"github.com/pkg/errors"
+)
+
+type StatsConfig struct {
+	Interval int `json:"interval"` // ticks between samples
+	Samples  int `json:"samples"`  // number of samples kept
+
+	// .csv or .json file the samples are written to on exit, "" disables
+	Export string `json:"export"`
+}
+
+// Validate reports problems in the stats config, paths are relative to it.
+func (c *StatsConfig) Validate() []*blob.ValidationError {
+	var errs []*blob.ValidationError
+
+	if c.Samples < 0 {
+		errs = append(errs, &blob.ValidationError{
+			Path:    "samples",
+			Message: "number of samples must not be negative",
+		})
+	}
+
+	return errs
+}
+
+// ExportStats writes the samples to path, as CSV or JSON depending on its
+// extension.
+func ExportStats(path string, stats *blob.Stats) error {
+	write := stats.WriteJSON
+
+	switch filepath.Ext(path) {
+	case ".json":
+	case ".csv":
+		write = stats.WriteCSV
+	default:
+		return errors.Errorf("unknown stats format %q", filepath.Ext(path))
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return errors.Wrap(err, "failed to create stats directory")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create stats file")
+	}
+
+	defer f.Close()
+
+	err = write(f)
+	if err != nil {
+		return errors.Wrap(err, "failed to write stats")
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"image/color"
+	"math"
+
+	"private/grow/blob"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+const blueprintRotation = math.Pi / 12 // 15 degrees
+
+// BlueprintStore keeps named blueprints between runs.
+type BlueprintStore interface {
+	Names() ([]string, error)
+	Load(name string) (*blob.Blueprint, error)
+	Save(bp *blob.Blueprint) error
+}
+
+// refreshBlueprints regenerates the blueprint palette from the store, so that
+// blueprints saved since it was last opened show up.
+func (e *Editor) refreshBlueprints() {
+	old := make(map[*button]bool, len(e.buttons.blueprints))
+	for _, btn := range e.buttons.blueprints {
+		old[btn] = true
+	}
+
+	var buttons []*button
+
+	for _, btn := range e.allbuttons {
+		if !old[btn] {
+			buttons = append(buttons, btn)
+		}
+	}
+
+	e.allbuttons = buttons
+	e.buttons.blueprints = nil
+
+	if e.blueprints == nil {
+		return
+	}
+
+	names, err := e.blueprints.Names()
+	if err != nil {
+		return
+	}
+
+	for i, name := range names {
+		name := name
+
+		btn := newButton(pixel.V(355, float64(18+12*i)), name, true)
+
+		btn.onClick = func(_ *button) {
+			e.hideMenus()
+
+			bp, err := e.blueprints.Load(name)
+			if err != nil {
+				return
+			}
+
+			e.mode = EditorModePlace
+			e.blueprint = bp
+			e.blueprintAngle = 0
+		}
+
+		e.buttons.blueprints = append(e.buttons.blueprints, btn)
+	}
+
+	e.allbuttons = append(e.allbuttons, e.buttons.blueprints...)
+}
+
+// updatePlace handles the place blueprint mode. R rotates the blueprint and
+// clicking places it at the cursor, connected to the closest existing node.
+func (e *Editor) updatePlace() {
+	if e.blueprint == nil {
+		e.mode = EditorModeNone
+		return
+	}
+
+	if e.win.JustPressed(pixelgl.KeyR) {
+		if e.shiftPressed() {
+			e.blueprintAngle -= blueprintRotation
+		} else {
+			e.blueprintAngle += blueprintRotation
+		}
+	}
+
+	if !e.win.JustPressed(pixelgl.MouseButtonLeft) {
+		return
+	}
+
+	bp := e.blueprint.Rotated(e.blueprintAngle)
+
+	pos := e.grid.Snap(e.view.MousePos())
+	if !e.blueprintFits(bp, pos) {
+		return
+	}
+
+	cmd := &placeBlueprintCommand{blueprint: bp, pos: pos, attach: true}
+
+	err := e.history.Do(cmd)
+	if err != nil {
+		return
+	}
+
+	e.selected = make(map[int]bool)
+	for _, id := range cmd.ids {
+		e.selected[id] = true
+	}
+}
+
+// blueprintFits returns whether all nodes of the blueprint have room when it
+// is placed at pos.
+func (e *Editor) blueprintFits(bp *blob.Blueprint, pos pixel.Vec) bool {
+	for _, node := range bp.Nodes {
+		if !e.blob.HasRoom(
+			pos.Add(node.Pos),
+			node.NodeType,
+			e.editorConf.MinSpacing,
+			nil,
+		) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *Editor) renderBlueprint() {
+	if e.blueprint == nil {
+		return
+	}
+
+	bp := e.blueprint.Rotated(e.blueprintAngle)
+	pos := e.grid.Snap(e.view.MousePos())
+
+	positions := make(map[int]pixel.Vec, len(bp.Nodes))
+
+	imd := imdraw.New(nil)
+	imd.Color = color.RGBA{150, 150, 150, 255}
+
+	for _, node := range bp.Nodes {
+		positions[node.ID] = pos.Add(node.Pos)
+
+		radius := 10.0
+		if conf, ok := e.conf.Nodes[node.NodeType]; ok {
+			radius = conf.Radius
+		}
+
+		imd.Push(positions[node.ID])
+		imd.Circle(radius, 1)
+	}
+
+	for _, conn := range bp.Connections {
+		imd.Push(positions[conn.Nodes.Node1], positions[conn.Nodes.Node2])
+		imd.Line(1)
+	}
+
+	imd.Draw(e.win)
+}
+
+// startNaming prompts for the name of a blueprint of the selected nodes.
+func (e *Editor) startNaming() {
+	if len(e.selected) == 0 || e.blueprints == nil {
+		return
+	}
+
+	e.naming = true
+	e.name = ""
+}
+
+// Typing returns whether the editor is reading typed text, in which case keys
+// should not be handled elsewhere.
+func (e *Editor) Typing() bool {
+	return e.naming
+}
+
+// updateNaming reads the typed name, enter saves the blueprint and escape
+// cancels.
+func (e *Editor) updateNaming() {
+	if e.win.JustPressed(pixelgl.KeyEscape) {
+		e.naming = false
+		return
+	}
+
+	if e.win.JustPressed(pixelgl.KeyBackspace) && len(e.name) > 0 {
+		runes := []rune(e.name)
+		e.name = string(runes[:len(runes)-1])
+	}
+
+	e.name += e.win.Typed()
+
+	if !e.win.JustPressed(pixelgl.KeyEnter) {
+		return
+	}
+
+	bp, err := e.blob.Blueprint(e.selectedIDs())
+	if err != nil {
+		e.naming = false
+		return
+	}
+
+	bp.Name = e.name
+
+	err = e.blueprints.Save(bp)
+	if err != nil {
+		return // keep the prompt open to fix the name
+	}
+
+	e.naming = false
+}
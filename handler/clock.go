@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+const defaultTicksPerSecond = 60
+
+type ClockConfig struct {
+	TicksPerSecond   float64 `json:"ticks_per_second"`
+	MaxTicksPerFrame int     `json:"max_ticks_per_frame"`
+}
+
+// Clock runs simulation ticks at a fixed logical rate, independent of the
+// frame rate. Elapsed real time is accumulated and spent in whole ticks, the
+// remainder is exposed by Alpha to interpolate rendering between ticks.
+type Clock struct {
+	win          *pixelgl.Window
+	prevTime     time.Time
+	accumulator  time.Duration
+	tickDuration time.Duration
+	speed        float64
+	paused       bool
+	step         bool
+
+	conf *ClockConfig
+}
+
+func NewClock(conf *ClockConfig, win *pixelgl.Window) *Clock {
+	tps := conf.TicksPerSecond
+	if tps <= 0 {
+		tps = defaultTicksPerSecond
+	}
+
+	return &Clock{
+		win:          win,
+		prevTime:     time.Now(),
+		tickDuration: time.Duration(float64(time.Second) / tps),
+		speed:        1,
+		conf:         conf,
+	}
+}
+
+// Update handles speed controls: space pauses, 1, 2 and 4 set the
+// simulation speed and period advances a single tick while paused.
+func (c *Clock) Update() {
+	if c.win.JustPressed(pixelgl.KeySpace) {
+		c.paused = !c.paused
+	}
+
+	if c.win.JustPressed(pixelgl.Key1) {
+		c.speed = 1
+	}
+
+	if c.win.JustPressed(pixelgl.Key2) {
+		c.speed = 2
+	}
+
+	if c.win.JustPressed(pixelgl.Key4) {
+		c.speed = 4
+	}
+
+	if c.win.JustPressed(pixelgl.KeyPeriod) {
+		c.paused = true
+		c.step = true
+	}
+}
+
+// Ticks returns the number of simulation ticks to run for the time elapsed
+// since the previous call. Call it once per frame.
+func (c *Clock) Ticks() int {
+	now := time.Now()
+	elapsed := now.Sub(c.prevTime)
+	c.prevTime = now
+
+	if c.paused {
+		c.accumulator = 0
+
+		if c.step {
+			c.step = false
+			return 1
+		}
+
+		return 0
+	}
+
+	c.accumulator += time.Duration(float64(elapsed) * c.speed)
+
+	ticks := int(c.accumulator / c.tickDuration)
+	c.accumulator -= time.Duration(ticks) * c.tickDuration
+
+	// drop the backlog instead of falling further behind when the simulation
+	// can not keep up.
+	if c.conf.MaxTicksPerFrame > 0 && ticks > c.conf.MaxTicksPerFrame {
+		ticks = c.conf.MaxTicksPerFrame
+		c.accumulator = 0
+	}
+
+	return ticks
+}
+
+// Alpha returns how far, from 0 to 1, the clock is between the last and the
+// next tick.
+func (c *Clock) Alpha() float64 {
+	if c.paused {
+		return 1
+	}
+
+	return float64(c.accumulator) / float64(c.tickDuration)
+}
+
+func (c *Clock) Paused() bool {
+	return c.paused
+}
+
+func (c *Clock) Speed() float64 {
+	return c.speed
+}
@@ -0,0 +1,303 @@
+package handler
+
+import (
+	"errors"
+
+	"private/grow/blob"
+
+	"github.com/faiface/pixel"
+)
+
+type addNodeCommand struct {
+	pos      pixel.Vec
+	nodeType blob.NodeType
+	id       int
+}
+
+func (c *addNodeCommand) Do(b *blob.Blob) error {
+	c.id = b.AddNode(c.pos, c.nodeType)
+	return nil
+}
+
+func (c *addNodeCommand) Undo(b *blob.Blob) error {
+	return b.RemoveNode(c.id)
+}
+
+type connectCommand struct {
+	id1      int
+	id2      int
+	connType blob.ConnectionType
+}
+
+func (c *connectCommand) Do(b *blob.Blob) error {
+	if b.Connected(c.id1, c.id2) {
+		return errors.New("nodes are already connected")
+	}
+
+	_, err := b.Connect(c.id1, c.id2, c.connType)
+
+	return err
+}
+
+func (c *connectCommand) Undo(b *blob.Blob) error {
+	err := b.Disconnect(c.id1, c.id2)
+	if err != nil {
+		return err
+	}
+
+	b.RefundBuildCost(c.connType)
+
+	return nil
+}
+
+type disconnectCommand struct {
+	id1  int
+	id2  int
+	conn blob.Connection
+}
+
+func (c *disconnectCommand) Do(b *blob.Blob) error {
+	conn := b.GetConnection(blob.NewConnectionIDs(c.id1, c.id2))
+	if conn == nil {
+		return errors.New("nodes are not connected")
+	}
+
+	c.conn = *conn
+
+	return b.Disconnect(c.id1, c.id2)
+}
+
+func (c *disconnectCommand) Undo(b *blob.Blob) error {
+	return b.RestoreConnection(&c.conn)
+}
+
+type addUnitCommand struct {
+	nodeID int
+	id     int
+}
+
+func (c *addUnitCommand) Do(b *blob.Blob) error {
+	c.id = b.AddUnit(c.nodeID).ID()
+	return nil
+}
+
+func (c *addUnitCommand) Undo(b *blob.Blob) error {
+	return b.RemoveUnit(c.id)
+}
+
+type removeUnitsCommand struct {
+	units []*blob.UnitJSON
+}
+
+func (c *removeUnitsCommand) Do(b *blob.Blob) error {
+	c.units = nil
+	for _, id := range blob.SortedKeys(b.Units) {
+		c.units = append(c.units, b.Units[id].ToJSON())
+	}
+
+	b.RemoveUnits()
+
+	return nil
+}
+
+func (c *removeUnitsCommand) Undo(b *blob.Blob) error {
+	for _, unit := range c.units {
+		// units whose node is gone stay removed
+		_ = b.RestoreUnit(unit)
+	}
+
+	return nil
+}
+
+type removeResourcesCommand struct {
+	stock map[int]map[blob.ResourceType][]pixel.Vec
+}
+
+func (c *removeResourcesCommand) Do(b *blob.Blob) error {
+	c.stock = b.Resources()
+	b.RemoveResources()
+
+	return nil
+}
+
+func (c *removeResourcesCommand) Undo(b *blob.Blob) error {
+	b.RestoreResources(c.stock)
+	return nil
+}
+
+type removeNodesCommand struct {
+	ids    []int
+	states []*blob.NodeState
+}
+
+func (c *removeNodesCommand) Do(b *blob.Blob) error {
+	c.states = nil
+
+	for _, id := range c.ids {
+		state, err := b.NodeState(id)
+		if err != nil {
+			return err
+		}
+
+		c.states = append(c.states, state)
+	}
+
+	for _, id := range c.ids {
+		err := b.RemoveNode(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *removeNodesCommand) Undo(b *blob.Blob) error {
+	// connections between the removed nodes are restored with the later one
+	for _, state := range c.states {
+		err := b.RestoreNode(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type moveNodesCommand struct {
+	from map[int]pixel.Vec
+	to   map[int]pixel.Vec
+}
+
+func (c *moveNodesCommand) Do(b *blob.Blob) error {
+	return moveNodes(b, c.to)
+}
+
+func (c *moveNodesCommand) Undo(b *blob.Blob) error {
+	return moveNodes(b, c.from)
+}
+
+func moveNodes(b *blob.Blob, positions map[int]pixel.Vec) error {
+	for _, id := range blob.SortedKeys(positions) {
+		err := b.MoveNode(id, positions[id])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type placeBlueprintCommand struct {
+	blueprint *blob.Blueprint
+	pos       pixel.Vec
+	attach    bool // connect to the closest node outside of the blueprint
+	ids       []int
+	attached  bool
+}
+
+func (c *placeBlueprintCommand) Do(b *blob.Blob) error {
+	ids, err := b.PlaceBlueprint(c.blueprint, c.pos)
+	if err != nil {
+		return err
+	}
+
+	c.ids = ids
+	c.attached = false
+
+	if !c.attach {
+		return nil
+	}
+
+	inside, outside, err := b.ClosestNodes(ids)
+	if err != nil {
+		return nil // nothing to attach to
+	}
+
+	_, err = b.Connect(inside, outside, "")
+	c.attached = err == nil
+
+	return nil
+}
+
+func (c *placeBlueprintCommand) Undo(b *blob.Blob) error {
+	for _, id := range c.ids {
+		err := b.RemoveNode(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, conn := range c.blueprint.Connections {
+		b.RefundBuildCost(conn.Type)
+	}
+
+	if c.attached {
+		b.RefundBuildCost("")
+	}
+
+	return nil
+}
+
+type setNodeTypeCommand struct {
+	id       int
+	nodeType blob.NodeType
+	state    *blob.NodeState
+}
+
+func (c *setNodeTypeCommand) Do(b *blob.Blob) error {
+	state, err := b.NodeState(c.id)
+	if err != nil {
+		return err
+	}
+
+	c.state = state
+
+	return b.SetNodeType(c.id, c.nodeType)
+}
+
+func (c *setNodeTypeCommand) Undo(b *blob.Blob) error {
+	return b.RestoreNode(c.state)
+}
+
+type setPriorityCommand struct {
+	id        int
+	res       blob.ResourceType
+	producer  bool // consumer priority otherwise
+	priority  int
+	prev      int
+	prevIndex int // among the nodes of the previous priority
+}
+
+func (c *setPriorityCommand) Do(b *blob.Blob) error {
+	info, err := b.InspectNode(c.id)
+	if err != nil {
+		return err
+	}
+
+	if c.producer {
+		c.prev = info.Produces[c.res]
+	} else {
+		c.prev = info.Consumes[c.res]
+	}
+
+	if c.prev == c.priority {
+		return errors.New("priority is unchanged")
+	}
+
+	if c.producer {
+		c.prevIndex, err = b.SetProducerPriority(c.id, c.res, c.priority)
+	} else {
+		c.prevIndex, err = b.SetConsumerPriority(c.id, c.res, c.priority)
+	}
+
+	return err
+}
+
+func (c *setPriorityCommand) Undo(b *blob.Blob) error {
+	if c.producer {
+		return b.RestoreProducerPriority(c.id, c.res, c.prev, c.prevIndex)
+	}
+
+	return b.RestoreConsumerPriority(c.id, c.res, c.prev, c.prevIndex)
+}
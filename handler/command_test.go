@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"private/grow/blob"
+
+	"github.com/faiface/pixel"
+)
+
+var priorityConf = &blob.BlobConfig{
+	Nodes: map[blob.NodeType]*blob.NodeConfig{
+		"store": {
+			Radius:           5,
+			ResourceCapacity: 5,
+			Consumes:         map[blob.ResourceType]int{"moss": 0},
+		},
+	},
+	Resources: map[blob.ResourceType]*blob.ResourceConfig{"moss": {}},
+	Unit:      &blob.UnitConfig{},
+}
+
+// mossConsumers returns the consumers of moss at priorities 0 and 3.
+func mossConsumers(b *blob.Blob) string {
+	consumers := b.ToJSON().Consumers["moss"]
+
+	return fmt.Sprint(consumers[0], consumers[3])
+}
+
+func TestSetPriorityUndoKeepsOrder(t *testing.T) {
+	b := blob.NewBlob(&blob.BlobJSON{}, priorityConf)
+	for i := 0; i < 3; i++ {
+		b.AddNode(pixel.V(float64(i*20), 0), "store")
+	}
+
+	h := NewHistory(b, 0)
+
+	err := h.Do(&setPriorityCommand{id: 0, res: "moss", priority: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mossConsumers(b); got != "[1 2] [0]" {
+		t.Fatalf("consumers %s after setting the priority", got)
+	}
+
+	err = h.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mossConsumers(b); got != "[0 1 2] []" {
+		t.Fatalf("consumers %s after undo, expected the order kept", got)
+	}
+
+	err = h.Redo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mossConsumers(b); got != "[1 2] [0]" {
+		t.Fatalf("consumers %s after redo", got)
+	}
+}
+
+func TestSetSamePriority(t *testing.T) {
+	b := blob.NewBlob(&blob.BlobJSON{}, priorityConf)
+	b.AddNode(pixel.V(0, 0), "store")
+
+	h := NewHistory(b, 0)
+
+	err := h.Do(&setPriorityCommand{id: 0, res: "moss", priority: 0})
+	if err == nil {
+		t.Fatal("expected an unchanged priority to be rejected")
+	}
+
+	if h.Undo() == nil {
+		t.Fatal("expected nothing to undo")
+	}
+}
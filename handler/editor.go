@@ -2,8 +2,10 @@ package handler
 
 import (
 	"image/color"
+	"math"
 
 	"private/grow/blob"
+	"private/grow/render"
 
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/imdraw"
@@ -19,73 +21,108 @@ const (
 	EditorModeAddNode      EditorMode = "add_node"
 	EditorModeConnectNodes EditorMode = "connect_nodes"
 	EditorModeAddUnit      EditorMode = "add_unit"
+	EditorModeRemoveNode   EditorMode = "remove_node"
+	EditorModeDisconnect   EditorMode = "disconnect"
+	EditorModeMoveNode     EditorMode = "move_node"
+	EditorModeSetNodeType  EditorMode = "set_node_type"
+	EditorModeSelect       EditorMode = "select"
+	EditorModePlace        EditorMode = "place_blueprint"
 )
 
-type Editor struct {
-	win  *pixelgl.Window
-	view *View
-	blob *blob.Blob
+type EditorConfig struct {
+	HistoryLimit int        `json:"history_limit"` // undoable operations
+	Grid         GridConfig `json:"grid"`
+	AngleSnap    float64    `json:"angle_snap"`  // degrees, 0 to turn off
+	MinSpacing   float64    `json:"min_spacing"` // between node edges
+}
 
-	mode        EditorMode
-	addNodeType blob.NodeType
-	target      int
-	targetSet   bool
+type Editor struct {
+	win        *pixelgl.Window
+	view       *View
+	blob       *blob.Blob
+	history    *History
+	blueprints BlueprintStore
+	conf       *blob.BlobConfig
+	editorConf *EditorConfig
+	grid       *Grid
+	rend       *render.Renderer
+	atlas      *text.Atlas // of the status bar and inspector
+
+	mode           EditorMode
+	addNodeType    blob.NodeType
+	setNodeType    blob.NodeType
+	connectionType blob.ConnectionType
+	target         int
+	targetSet      bool
+
+	dragging   bool
+	dragFrom   map[int]pixel.Vec // positions of the dragged nodes when grabbed
+	dragStart  pixel.Vec         // cursor position when grabbed
+	dragAnchor int               // node under the cursor, snapped to the grid
+	dragIDs    blob.Identifiers  // of the blob when grabbed
+
+	selected  map[int]bool
+	boxing    bool
+	boxStart  pixel.Vec
+	clipboard *blob.Blueprint
+
+	blueprint      *blob.Blueprint // being placed
+	blueprintAngle float64
+	naming         bool // typing the name of a blueprint to save
+	name           string
+
+	inspected       int
+	inspecting      bool
+	inspectedUnitID int
+	inspectingUnit  bool
 
 	buttons    *editorButtons
 	allbuttons []*button
 }
 
 type editorButtons struct {
-	addNode                       *button
-	addNodeNone                   *button
-	addNodeMosFarm                *button
-	addNodeMosFermentationChamber *button
-	addNodeMushroomFarm           *button
-	addNodeStorage                *button
-	connectNodes                  *button
-	addUnit                       *button
-	removeUnits                   *button
-	removeResources               *button
+	addNode         *button
+	addNodeTypes    []*button // generated from node types in config
+	connectNodes    *button
+	connectionTypes []*button // generated from connection types in config
+	addUnit         *button
+	removeUnits     *button
+	removeResources *button
+	remove          *button
+	removeModes     []*button
+	edit            *button
+	editModes       []*button
+	setNodeTypes    []*button // generated from node types in config
+	blueprints      []*button // generated from the blueprint library
 }
 
-func NewEditor(win *pixelgl.Window, view *View, b *blob.Blob) *Editor {
+func NewEditor(
+	win *pixelgl.Window,
+	view *View,
+	b *blob.Blob,
+	editorConf *EditorConfig,
+	conf *blob.BlobConfig,
+	blueprints BlueprintStore,
+) *Editor {
 	e := &Editor{
-		win:         win,
-		view:        view,
-		blob:        b,
-		mode:        EditorModeNone,
-		addNodeType: blob.NodeTypeNone,
+		win:        win,
+		view:       view,
+		blob:       b,
+		history:    NewHistory(b, editorConf.HistoryLimit),
+		blueprints: blueprints,
+		conf:       conf,
+		editorConf: editorConf,
+		grid:       NewGrid(&editorConf.Grid),
+		rend:       render.NewRenderer(win),
+		atlas:      text.NewAtlas(basicfont.Face7x13, text.ASCII),
+		mode:       EditorModeNone,
+		selected:   make(map[int]bool),
 		buttons: &editorButtons{
 			addNode: newButton(
 				pixel.V(10, 6),
 				"add node",
 				false,
 			),
-			addNodeNone: newButton(
-				pixel.V(7, 18),
-				"none",
-				true,
-			),
-			addNodeMosFarm: newButton(
-				pixel.V(10, 30),
-				"mos farm",
-				true,
-			),
-			addNodeMosFermentationChamber: newButton(
-				pixel.V(24, 42),
-				"mos fermentation chamber",
-				true,
-			),
-			addNodeMushroomFarm: newButton(
-				pixel.V(15, 54),
-				"mushroom farm",
-				true,
-			),
-			addNodeStorage: newButton(
-				pixel.V(10, 66),
-				"storage",
-				true,
-			),
 			connectNodes: newButton(
 				pixel.V(58, 6),
 				"connect nodes",
@@ -106,116 +143,233 @@ func NewEditor(win *pixelgl.Window, view *View, b *blob.Blob) *Editor {
 				"remove resources",
 				false,
 			),
+			remove: newButton(
+				pixel.V(314, 6),
+				"remove",
+				false,
+			),
+			edit: newButton(
+				pixel.V(355, 6),
+				"edit",
+				false,
+			),
 		},
 	}
 
-	e.allbuttons = []*button{
-		e.buttons.addNode,
-		e.buttons.addNodeNone,
-		e.buttons.addNodeMosFarm,
-		e.buttons.addNodeMosFermentationChamber,
-		e.buttons.addNodeMushroomFarm,
-		e.buttons.addNodeStorage,
-		e.buttons.connectNodes,
-		e.buttons.addUnit,
-		e.buttons.removeUnits,
-		e.buttons.removeResources,
+	removeModes := []struct {
+		label string
+		mode  EditorMode
+	}{
+		{"node", EditorModeRemoveNode},
+		{"connection", EditorModeDisconnect},
 	}
 
-	e.buttons.addNode.onClick = func(_ *button) {
-		e.buttons.addNodeNone.hidden = !e.buttons.addNodeNone.hidden
-		e.buttons.addNodeMosFarm.hidden = !e.buttons.addNodeMosFarm.hidden
-		e.buttons.addNodeMosFermentationChamber.hidden = !e.buttons.addNodeMosFermentationChamber.hidden
-		e.buttons.addNodeMushroomFarm.hidden = !e.buttons.addNodeMushroomFarm.hidden
-		e.buttons.addNodeStorage.hidden = !e.buttons.addNodeStorage.hidden
+	for i, removeMode := range removeModes {
+		mode := removeMode.mode
+
+		btn := newButton(
+			pixel.V(314, float64(18+12*i)),
+			removeMode.label,
+			true,
+		)
+
+		btn.onClick = func(_ *button) {
+			e.hideMenus()
+
+			e.mode = mode
+		}
+
+		e.buttons.removeModes = append(e.buttons.removeModes, btn)
 	}
 
-	e.buttons.addNodeNone.onClick = func(_ *button) {
-		e.buttons.addNodeNone.hidden = true
-		e.buttons.addNodeMosFarm.hidden = true
-		e.buttons.addNodeMosFermentationChamber.hidden = true
-		e.buttons.addNodeMushroomFarm.hidden = true
-		e.buttons.addNodeStorage.hidden = true
+	moveNode := newButton(pixel.V(355, 18), "move node", true)
+	moveNode.onClick = func(_ *button) {
+		e.hideMenus()
+
+		e.mode = EditorModeMoveNode
+	}
 
-		e.mode = EditorModeAddNode
-		e.addNodeType = blob.NodeTypeNone
+	changeType := newButton(pixel.V(355, 30), "change type", true)
+	changeType.onClick = func(_ *button) {
+		e.toggleMenu(e.buttons.setNodeTypes)
 	}
 
-	e.buttons.addNodeMosFarm.onClick = func(_ *button) {
-		e.buttons.addNodeNone.hidden = true
-		e.buttons.addNodeMosFarm.hidden = true
-		e.buttons.addNodeMosFermentationChamber.hidden = true
-		e.buttons.addNodeMushroomFarm.hidden = true
-		e.buttons.addNodeStorage.hidden = true
+	selectNodes := newButton(pixel.V(355, 42), "select", true)
+	selectNodes.onClick = func(_ *button) {
+		e.hideMenus()
+
+		e.mode = EditorModeSelect
+	}
 
-		e.mode = EditorModeAddNode
-		e.addNodeType = blob.NodeTypeMossFarm
+	showBlueprints := newButton(pixel.V(355, 54), "blueprints", true)
+	showBlueprints.onClick = func(_ *button) {
+		e.refreshBlueprints()
+		e.toggleMenu(e.buttons.blueprints)
 	}
 
-	e.buttons.addNodeMosFermentationChamber.onClick = func(_ *button) {
-		e.buttons.addNodeNone.hidden = true
-		e.buttons.addNodeMosFarm.hidden = true
-		e.buttons.addNodeMosFermentationChamber.hidden = true
-		e.buttons.addNodeMushroomFarm.hidden = true
-		e.buttons.addNodeStorage.hidden = true
+	saveBlueprint := newButton(pixel.V(355, 66), "save blueprint", true)
+	saveBlueprint.onClick = func(_ *button) {
+		e.hideMenus()
+		e.startNaming()
+	}
 
-		e.mode = EditorModeAddNode
-		e.addNodeType = blob.NodeTypeMossFermentationChamber
+	e.buttons.editModes = []*button{
+		moveNode,
+		changeType,
+		selectNodes,
+		showBlueprints,
+		saveBlueprint,
 	}
 
-	e.buttons.addNodeMushroomFarm.onClick = func(_ *button) {
-		e.buttons.addNodeNone.hidden = true
-		e.buttons.addNodeMosFarm.hidden = true
-		e.buttons.addNodeMosFermentationChamber.hidden = true
-		e.buttons.addNodeMushroomFarm.hidden = true
-		e.buttons.addNodeStorage.hidden = true
+	for i, nodeType := range conf.MenuNodeTypes() {
+		nodeType := nodeType
+
+		label := conf.Nodes[nodeType].Menu.Label
+		if label == "" {
+			label = string(nodeType)
+		}
+
+		btn := newButton(pixel.V(10, float64(18+12*i)), label, true)
+
+		btn.onClick = func(_ *button) {
+			e.hideMenus()
 
-		e.mode = EditorModeAddNode
-		e.addNodeType = blob.NodeTypeMushroomFarm
+			e.mode = EditorModeAddNode
+			e.addNodeType = nodeType
+		}
+
+		e.buttons.addNodeTypes = append(e.buttons.addNodeTypes, btn)
+
+		setBtn := newButton(pixel.V(355, float64(18+12*i)), label, true)
+
+		setBtn.onClick = func(_ *button) {
+			e.hideMenus()
+
+			e.mode = EditorModeSetNodeType
+			e.setNodeType = nodeType
+		}
+
+		e.buttons.setNodeTypes = append(e.buttons.setNodeTypes, setBtn)
 	}
 
-	e.buttons.addNodeStorage.onClick = func(_ *button) {
-		e.buttons.addNodeNone.hidden = true
-		e.buttons.addNodeMosFarm.hidden = true
-		e.buttons.addNodeMosFermentationChamber.hidden = true
-		e.buttons.addNodeMushroomFarm.hidden = true
-		e.buttons.addNodeStorage.hidden = true
+	for i, connType := range conf.MenuConnectionTypes() {
+		connType := connType
+
+		label := conf.Connections[connType].Menu.Label
+		if label == "" {
+			label = string(connType)
+		}
+
+		btn := newButton(pixel.V(58, float64(18+12*i)), label, true)
 
-		e.mode = EditorModeAddNode
-		e.addNodeType = blob.NodeTypeStorage
+		btn.onClick = func(_ *button) {
+			e.hideMenus()
+
+			e.mode = EditorModeConnectNodes
+			e.connectionType = connType
+			e.targetSet = false
+		}
+
+		e.buttons.connectionTypes = append(e.buttons.connectionTypes, btn)
+	}
+
+	e.allbuttons = append(
+		[]*button{e.buttons.addNode},
+		e.buttons.addNodeTypes...,
+	)
+	e.allbuttons = append(e.allbuttons, e.buttons.connectNodes)
+	e.allbuttons = append(e.allbuttons, e.buttons.connectionTypes...)
+	e.allbuttons = append(
+		e.allbuttons,
+		e.buttons.addUnit,
+		e.buttons.removeUnits,
+		e.buttons.removeResources,
+		e.buttons.remove,
+	)
+	e.allbuttons = append(e.allbuttons, e.buttons.removeModes...)
+	e.allbuttons = append(e.allbuttons, e.buttons.edit)
+	e.allbuttons = append(e.allbuttons, e.buttons.editModes...)
+	e.allbuttons = append(e.allbuttons, e.buttons.setNodeTypes...)
+
+	e.buttons.addNode.onClick = func(_ *button) {
+		e.toggleMenu(e.buttons.addNodeTypes)
 	}
 
 	e.buttons.connectNodes.onClick = func(_ *button) {
-		e.buttons.addNodeNone.hidden = true
-		e.buttons.addNodeMosFarm.hidden = true
-		e.buttons.addNodeMosFermentationChamber.hidden = true
-		e.buttons.addNodeMushroomFarm.hidden = true
-		e.buttons.addNodeStorage.hidden = true
+		if len(e.buttons.connectionTypes) > 0 {
+			e.toggleMenu(e.buttons.connectionTypes)
+			return
+		}
 
+		// no connection types in the menu, build the default one
 		e.mode = EditorModeConnectNodes
+		e.connectionType = ""
+		e.targetSet = false
 	}
 
 	e.buttons.addUnit.onClick = func(_ *button) {
-		e.buttons.addNodeNone.hidden = true
-		e.buttons.addNodeMosFarm.hidden = true
-		e.buttons.addNodeMosFermentationChamber.hidden = true
-		e.buttons.addNodeMushroomFarm.hidden = true
-		e.buttons.addNodeStorage.hidden = true
+		e.hideMenus()
 
 		e.mode = EditorModeAddUnit
 	}
 
 	e.buttons.removeUnits.onClick = func(_ *button) {
-		e.blob.RemoveUnits()
+		e.history.Do(&removeUnitsCommand{})
 	}
 
 	e.buttons.removeResources.onClick = func(_ *button) {
-		e.blob.RemoveResources()
+		e.history.Do(&removeResourcesCommand{})
+	}
+
+	e.buttons.remove.onClick = func(_ *button) {
+		e.toggleMenu(e.buttons.removeModes)
+	}
+
+	e.buttons.edit.onClick = func(_ *button) {
+		e.toggleMenu(e.buttons.editModes)
 	}
 
 	return e
 }
 
+// toggleMenu shows the menu's buttons if they are hidden, hiding all other
+// menus, and hides them otherwise.
+func (e *Editor) toggleMenu(menu []*button) {
+	show := len(menu) > 0 && menu[0].hidden
+
+	e.hideMenus()
+
+	for _, btn := range menu {
+		btn.hidden = !show
+	}
+}
+
+func (e *Editor) hideMenus() {
+	for _, btn := range e.buttons.addNodeTypes {
+		btn.hidden = true
+	}
+
+	for _, btn := range e.buttons.connectionTypes {
+		btn.hidden = true
+	}
+
+	for _, btn := range e.buttons.removeModes {
+		btn.hidden = true
+	}
+
+	for _, btn := range e.buttons.editModes {
+		btn.hidden = true
+	}
+
+	for _, btn := range e.buttons.setNodeTypes {
+		btn.hidden = true
+	}
+
+	for _, btn := range e.buttons.blueprints {
+		btn.hidden = true
+	}
+}
+
 func (e *Editor) Update() {
 	for _, button := range e.allbuttons {
 		if button.clicked {
@@ -237,15 +391,86 @@ func (e *Editor) Update() {
 		}
 	}
 
+	if e.naming {
+		e.updateNaming()
+		return
+	}
+
+	if e.updateInspector() {
+		return
+	}
+
+	if e.ctrlPressed() {
+		if e.win.JustPressed(pixelgl.KeyZ) {
+			e.dragging = false
+			e.history.Undo()
+		}
+
+		if e.win.JustPressed(pixelgl.KeyY) {
+			e.dragging = false
+			e.history.Redo()
+		}
+	}
+
+	if e.win.JustPressed(pixelgl.KeyG) && !e.ctrlPressed() {
+		e.grid.Toggle()
+	}
+
 	if e.win.JustPressed(pixelgl.KeyEscape) {
 		e.mode = EditorModeNone
-		e.buttons.addNodeNone.hidden = true
+		e.dragging = false
+		e.boxing = false
+		e.selected = make(map[int]bool)
+		e.inspecting = false
+		e.inspectingUnit = false
+		e.hideMenus()
+	}
+
+	e.updateSelectionKeys()
+
+	if e.dragging {
+		e.updateDrag()
+		return
+	}
+
+	switch e.mode {
+	case EditorModeNone:
+		e.updateInspect()
+		return
+	case EditorModeMoveNode:
+		if e.win.JustPressed(pixelgl.MouseButtonLeft) {
+			id, err := e.blob.GetNodeAt(e.view.MousePos())
+			if err == nil {
+				e.startDrag(id, []int{id})
+			}
+		}
+
+		return
+	case EditorModeSelect:
+		e.updateSelect()
+		return
+	case EditorModePlace:
+		e.updatePlace()
+		return
 	}
 
 	if e.win.JustPressed(pixelgl.MouseButtonLeft) {
 		switch e.mode {
 		case EditorModeAddNode:
-			e.blob.AddNode(e.view.MousePos(), e.addNodeType)
+			pos := e.grid.Snap(e.view.MousePos())
+			if !e.blob.HasRoom(
+				pos,
+				e.addNodeType,
+				e.editorConf.MinSpacing,
+				nil,
+			) {
+				return
+			}
+
+			e.history.Do(&addNodeCommand{
+				pos:      pos,
+				nodeType: e.addNodeType,
+			})
 		case EditorModeConnectNodes:
 			if !e.targetSet {
 				id, err := e.blob.GetClosestNode(e.view.MousePos())
@@ -257,29 +482,166 @@ func (e *Editor) Update() {
 				return
 			}
 
-			id, err := e.blob.GetClosestNode(e.view.MousePos())
+			id, err := e.blob.GetClosestNode(e.connectPos())
 			if err == nil {
-				e.blob.Connect(e.target, id)
+				e.history.Do(&connectCommand{
+					id1:      e.target,
+					id2:      id,
+					connType: e.connectionType,
+				})
 			}
 
 			e.targetSet = false
 		case EditorModeAddUnit:
 			id, err := e.blob.GetClosestNode(e.view.MousePos())
 			if err == nil {
-				e.blob.AddUnit(id)
+				e.history.Do(&addUnitCommand{nodeID: id})
+			}
+		case EditorModeRemoveNode:
+			id, err := e.blob.GetNodeAt(e.view.MousePos())
+			if err == nil {
+				e.history.Do(&removeNodesCommand{ids: []int{id}})
+			}
+		case EditorModeDisconnect:
+			conn, err := e.blob.GetConnectionAt(e.view.MousePos(), 10)
+			if err == nil {
+				e.history.Do(&disconnectCommand{
+					id1: conn.Nodes.Node1,
+					id2: conn.Nodes.Node2,
+				})
+			}
+		case EditorModeSetNodeType:
+			id, err := e.blob.GetNodeAt(e.view.MousePos())
+			if err == nil {
+				e.history.Do(&setNodeTypeCommand{
+					id:       id,
+					nodeType: e.setNodeType,
+				})
 			}
 		}
 	}
 }
 
+func (e *Editor) ctrlPressed() bool {
+	return e.win.Pressed(pixelgl.KeyLeftControl) ||
+		e.win.Pressed(pixelgl.KeyRightControl)
+}
+
+func (e *Editor) shiftPressed() bool {
+	return e.win.Pressed(pixelgl.KeyLeftShift) ||
+		e.win.Pressed(pixelgl.KeyRightShift)
+}
+
+// connectPos returns the cursor position in connect mode, snapped to the
+// closest angle step around the first node.
+func (e *Editor) connectPos() pixel.Vec {
+	target, ok := e.blob.Nodes[e.target]
+	if !e.targetSet || !ok {
+		return e.view.MousePos()
+	}
+
+	return snapAngle(
+		target.Pos(),
+		e.view.MousePos(),
+		e.editorConf.AngleSnap*math.Pi/180,
+	)
+}
+
+// startDrag grabs the nodes, they follow the cursor until the left mouse
+// button is released. The anchor is the node under the cursor, it is the one
+// snapped to the grid.
+func (e *Editor) startDrag(anchor int, ids []int) {
+	e.dragging = true
+	e.dragAnchor = anchor
+	e.dragStart = e.view.MousePos()
+	e.dragIDs = e.blob.Identifiers()
+	e.dragFrom = make(map[int]pixel.Vec, len(ids))
+
+	for _, id := range ids {
+		e.dragFrom[id] = e.blob.Nodes[id].Pos()
+	}
+}
+
+func (e *Editor) updateDrag() {
+	if !e.win.Pressed(pixelgl.MouseButtonLeft) {
+		e.dragging = false
+
+		to := make(map[int]pixel.Vec, len(e.dragFrom))
+		moved := false
+
+		for id, from := range e.dragFrom {
+			node, ok := e.blob.Nodes[id]
+			if !ok {
+				return
+			}
+
+			to[id] = node.Pos()
+			moved = moved || to[id] != from
+		}
+
+		if moved {
+			e.history.Record(
+				&moveNodesCommand{from: e.dragFrom, to: to},
+				e.dragIDs,
+			)
+		}
+
+		return
+	}
+
+	delta := e.view.MousePos().Sub(e.dragStart)
+
+	if anchor, ok := e.dragFrom[e.dragAnchor]; ok {
+		delta = e.grid.Snap(anchor.Add(delta)).Sub(anchor)
+	}
+
+	ignore := make(map[int]bool, len(e.dragFrom))
+	for id := range e.dragFrom {
+		ignore[id] = true
+	}
+
+	// the nodes stay where they are until they all have room
+	for id, from := range e.dragFrom {
+		node, ok := e.blob.Nodes[id]
+		if !ok {
+			continue
+		}
+
+		if !e.blob.HasRoom(
+			from.Add(delta),
+			node.NodeType(),
+			e.editorConf.MinSpacing,
+			ignore,
+		) {
+			return
+		}
+	}
+
+	for _, id := range blob.SortedKeys(e.dragFrom) {
+		e.blob.MoveNode(id, e.dragFrom[id].Add(delta))
+	}
+}
+
 func (e *Editor) Render() {
-	// TODO: render indicators for each editor mode. ghost node for add node,
-	// etc.
+	imd := imdraw.New(nil)
+	e.grid.render(imd, e.view.Bounds())
+	imd.Draw(e.win)
+
+	e.renderIndicators()
+	e.renderInspected()
+	e.renderSelection()
+
+	if e.mode == EditorModePlace {
+		e.renderBlueprint()
+	}
 
 	e.view.UndoTransform()
 	for _, button := range e.allbuttons {
 		button.render(e.win)
 	}
+
+	e.renderStatus()
+	e.renderInspector()
 	e.view.Transform()
 }
 
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"fmt"
+	"image/color"
+
+	"private/grow/blob"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	graphWidth   = 180
+	graphHeight  = 70
+	graphSpacing = 18 // room for the title above each graph
+	graphColumns = 2
+	graphBottom  = 170 // above the editor's menus
+)
+
+var (
+	graphBackground = color.RGBA{20, 20, 20, 200}
+	graphColors     = []color.RGBA{
+		{220, 220, 220, 255},
+		{80, 220, 80, 255},
+		{220, 160, 40, 255},
+		{200, 40, 40, 255},
+	}
+)
+
+// StatsOverlay draws the colony's stats as line graphs over the game, it is
+// shown and hidden with tab.
+type StatsOverlay struct {
+	win   *pixelgl.Window
+	view  *View
+	stats *blob.Stats
+	conf  *blob.BlobConfig
+	atlas *text.Atlas
+	shown bool
+}
+
+type graph struct {
+	title  string
+	series []*graphSeries
+}
+
+type graphSeries struct {
+	color  color.RGBA
+	values []float64
+}
+
+func NewStatsOverlay(
+	win *pixelgl.Window,
+	view *View,
+	stats *blob.Stats,
+	conf *blob.BlobConfig,
+) *StatsOverlay {
+	return &StatsOverlay{
+		win:   win,
+		view:  view,
+		stats: stats,
+		conf:  conf,
+		atlas: text.NewAtlas(basicfont.Face7x13, text.ASCII),
+	}
+}
+
+func (o *StatsOverlay) Update() {
+	if o.win.JustPressed(pixelgl.KeyTab) {
+		o.shown = !o.shown
+	}
+}
+
+func (o *StatsOverlay) Render() {
+	if !o.shown {
+		return
+	}
+
+	o.view.UndoTransform()
+	defer o.view.Transform()
+
+	for i, g := range o.graphs() {
+		pos := pixel.V(
+			float64(10+(i%graphColumns)*(graphWidth+10)),
+			float64(graphBottom+(i/graphColumns)*(graphHeight+graphSpacing)),
+		)
+
+		g.render(o.win, o.atlas, pixel.R(
+			pos.X,
+			pos.Y,
+			pos.X+graphWidth,
+			pos.Y+graphHeight,
+		))
+	}
+}
+
+// graphs turns the samples into series. Production and consumption are
+// shown per tick, so that they don't depend on the sampling interval.
+func (o *StatsOverlay) graphs() []*graph {
+	samples := o.stats.Samples()
+	interval := float64(o.stats.Interval())
+
+	resources := &graph{title: "resources"}
+	produced := &graph{title: "produced / tick"}
+	consumed := &graph{title: "consumed / tick"}
+
+	for _, res := range blob.SortedKeys(o.conf.Resources) {
+		res := res
+		c := o.resourceColor(res)
+
+		resources.series = append(resources.series, sampleSeries(
+			samples,
+			c,
+			func(s *blob.Sample) float64 { return float64(s.Resources[res]) },
+		))
+		produced.series = append(produced.series, sampleSeries(
+			samples,
+			c,
+			func(s *blob.Sample) float64 {
+				return float64(s.Produced[res]) / interval
+			},
+		))
+		consumed.series = append(consumed.series, sampleSeries(
+			samples,
+			c,
+			func(s *blob.Sample) float64 {
+				return float64(s.Consumed[res]) / interval
+			},
+		))
+	}
+
+	jobs := &graph{title: "jobs available/occupied/halted"}
+
+	for i, state := range []blob.JobState{
+		blob.JobStateAvailable,
+		blob.JobStateOccupied,
+		blob.JobStateHalted,
+	} {
+		state := state
+
+		jobs.series = append(jobs.series, sampleSeries(
+			samples,
+			graphColors[(i+1)%len(graphColors)],
+			func(s *blob.Sample) float64 { return float64(s.Jobs[state]) },
+		))
+	}
+
+	return []*graph{
+		resources,
+		{
+			title: "units",
+			series: []*graphSeries{sampleSeries(
+				samples,
+				graphColors[0],
+				func(s *blob.Sample) float64 { return float64(s.Units) },
+			)},
+		},
+		produced,
+		consumed,
+		{
+			title: "average hunger",
+			series: []*graphSeries{sampleSeries(
+				samples,
+				graphColors[2],
+				func(s *blob.Sample) float64 { return s.AverageHunger },
+			)},
+		},
+		{
+			title: "deaths",
+			series: []*graphSeries{sampleSeries(
+				samples,
+				graphColors[3],
+				func(s *blob.Sample) float64 { return float64(s.Deaths) },
+			)},
+		},
+		jobs,
+	}
+}
+
+// resourceColor returns the color of the resource's first primitive.
+func (o *StatsOverlay) resourceColor(res blob.ResourceType) color.RGBA {
+	conf, ok := o.conf.Resources[res]
+	if !ok || len(conf.Graphics) == 0 {
+		return graphColors[0]
+	}
+
+	return conf.Graphics[0].Color
+}
+
+func sampleSeries(
+	samples []*blob.Sample,
+	c color.RGBA,
+	value func(*blob.Sample) float64,
+) *graphSeries {
+	series := &graphSeries{
+		color:  c,
+		values: make([]float64, 0, len(samples)),
+	}
+
+	for _, s := range samples {
+		series.values = append(series.values, value(s))
+	}
+
+	return series
+}
+
+// render draws the series scaled to the largest value of the graph, with the
+// title and the largest value above it.
+func (g *graph) render(
+	win *pixelgl.Window,
+	atlas *text.Atlas,
+	rect pixel.Rect,
+) {
+	top := 0.0
+	for _, series := range g.series {
+		for _, v := range series.values {
+			if v > top {
+				top = v
+			}
+		}
+	}
+
+	imd := imdraw.New(nil)
+	imd.Color = graphBackground
+	imd.Push(rect.Min, rect.Max)
+	imd.Rectangle(0)
+
+	for _, series := range g.series {
+		if len(series.values) < 2 || top <= 0 {
+			continue
+		}
+
+		step := rect.W() / float64(len(series.values)-1)
+
+		imd.Color = series.color
+
+		for i, v := range series.values {
+			imd.Push(pixel.V(
+				rect.Min.X+float64(i)*step,
+				rect.Min.Y+v/top*rect.H(),
+			))
+		}
+
+		imd.Line(1)
+	}
+
+	imd.Draw(win)
+
+	txt := text.New(pixel.V(rect.Min.X, rect.Max.Y+4), atlas)
+	txt.WriteString(fmt.Sprintf("%s (max %.4g)", g.title, top))
+	txt.Draw(win, pixel.IM)
+}
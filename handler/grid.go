@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+)
+
+type GridShape string
+
+const (
+	GridShapeSquare GridShape = "square"
+	GridShapeHex    GridShape = "hex"
+)
+
+const maxGridCells = 10000 // grid is not drawn when zoomed out further
+
+type GridConfig struct {
+	Enabled  bool       `json:"enabled"`
+	Shape    GridShape  `json:"shape"`
+	CellSize float64    `json:"cell_size"` // distance between neighbours
+	Color    color.RGBA `json:"color"`
+}
+
+// Grid snaps positions to the centers of square or hex cells. Hex cells are
+// pointy topped, with rows of centers offset by half a cell.
+type Grid struct {
+	conf    *GridConfig
+	enabled bool
+}
+
+func NewGrid(conf *GridConfig) *Grid {
+	return &Grid{
+		conf:    conf,
+		enabled: conf.Enabled && conf.CellSize > 0,
+	}
+}
+
+func (g *Grid) Enabled() bool {
+	return g.enabled
+}
+
+// Toggle turns snapping and the overlay on or off.
+func (g *Grid) Toggle() {
+	g.enabled = !g.enabled && g.conf.CellSize > 0
+}
+
+// Snap returns the center of the cell pos is in, or pos if the grid is off.
+func (g *Grid) Snap(pos pixel.Vec) pixel.Vec {
+	if !g.enabled {
+		return pos
+	}
+
+	if g.conf.Shape == GridShapeHex {
+		return g.hexCenter(g.hexCell(pos))
+	}
+
+	size := g.conf.CellSize
+
+	return pixel.V(
+		math.Round(pos.X/size)*size,
+		math.Round(pos.Y/size)*size,
+	)
+}
+
+// hexCell returns the axial coordinates of the hex cell pos is in.
+func (g *Grid) hexCell(pos pixel.Vec) (int, int) {
+	size := g.conf.CellSize
+
+	r := pos.Y / (size * math.Sqrt(3) / 2)
+	q := pos.X/size - r/2
+	s := -q - r
+
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+
+	// the coordinates have to add up to zero, the one rounded the most is
+	// derived from the others
+	switch {
+	case dq > dr && dq > ds:
+		rq = -rr - rs
+	case dr > ds:
+		rr = -rq - rs
+	}
+
+	return int(rq), int(rr)
+}
+
+func (g *Grid) hexCenter(q, r int) pixel.Vec {
+	size := g.conf.CellSize
+
+	return pixel.V(
+		size*(float64(q)+float64(r)/2),
+		size*float64(r)*math.Sqrt(3)/2,
+	)
+}
+
+// render draws the grid over the visible bounds, lines between square cells
+// and a dot at the center of hex cells.
+func (g *Grid) render(imd *imdraw.IMDraw, bounds pixel.Rect) {
+	if !g.enabled {
+		return
+	}
+
+	size := g.conf.CellSize
+
+	cells := bounds.W() / size * bounds.H() / size
+	if cells > maxGridCells {
+		return
+	}
+
+	imd.Color = g.conf.Color
+
+	if g.conf.Shape == GridShapeHex {
+		minQ, minR := g.hexCell(bounds.Min)
+		maxQ, maxR := g.hexCell(bounds.Max)
+
+		// rows shift left going up, widen the range to cover the corners
+		rows := maxR - minR
+		for r := minR - 1; r <= maxR+1; r++ {
+			for q := minQ - rows - 1; q <= maxQ+rows+1; q++ {
+				center := g.hexCenter(q, r)
+				if !bounds.Contains(center) {
+					continue
+				}
+
+				imd.Push(center)
+				imd.Circle(1, 0)
+			}
+		}
+
+		return
+	}
+
+	// offset by half a cell so that the lines are between cell centers
+	start := g.Snap(bounds.Min).Sub(pixel.V(size/2, size/2))
+
+	for x := start.X; x <= bounds.Max.X; x += size {
+		imd.Push(pixel.V(x, bounds.Min.Y), pixel.V(x, bounds.Max.Y))
+		imd.Line(1)
+	}
+
+	for y := start.Y; y <= bounds.Max.Y; y += size {
+		imd.Push(pixel.V(bounds.Min.X, y), pixel.V(bounds.Max.X, y))
+		imd.Line(1)
+	}
+}
+
+// snapAngle returns pos rotated around origin to the closest multiple of step
+// radians, keeping its distance.
+func snapAngle(origin, pos pixel.Vec, step float64) pixel.Vec {
+	if step <= 0 {
+		return pos
+	}
+
+	d := pos.Sub(origin)
+	angle := math.Round(d.Angle()/step) * step
+
+	return origin.Add(pixel.V(d.Len(), 0).Rotated(angle))
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+func near(a, b pixel.Vec) bool {
+	return a.Sub(b).Len() < 1e-9
+}
+
+func TestSnapSquare(t *testing.T) {
+	g := NewGrid(&GridConfig{
+		Enabled:  true,
+		Shape:    GridShapeSquare,
+		CellSize: 10,
+	})
+
+	tests := []struct {
+		pos      pixel.Vec
+		expected pixel.Vec
+	}{
+		{pixel.V(0, 0), pixel.V(0, 0)},
+		{pixel.V(14, -16), pixel.V(10, -20)},
+		{pixel.V(-4.9, 5.1), pixel.V(0, 10)},
+		{pixel.V(-26, -34), pixel.V(-30, -30)},
+		{pixel.V(-104, 96), pixel.V(-100, 100)},
+	}
+
+	for _, test := range tests {
+		snapped := g.Snap(test.pos)
+		if !near(snapped, test.expected) {
+			t.Errorf(
+				"%v snapped to %v, expected %v",
+				test.pos,
+				snapped,
+				test.expected,
+			)
+		}
+	}
+
+	g.Toggle()
+
+	if pos := pixel.V(14, -16); g.Snap(pos) != pos {
+		t.Error("expected a disabled grid not to snap")
+	}
+}
+
+func TestSnapHex(t *testing.T) {
+	g := NewGrid(&GridConfig{Enabled: true, Shape: GridShapeHex, CellSize: 10})
+	h := 10 * math.Sqrt(3) / 2 // between rows
+
+	tests := []struct {
+		pos  pixel.Vec
+		q, r int
+	}{
+		{pixel.V(0, 0), 0, 0},
+		{pixel.V(4, 0), 0, 0},
+		{pixel.V(6, 0), 1, 0},
+		{pixel.V(-6, 0), -1, 0},
+		{pixel.V(5, h), 0, 1},
+		{pixel.V(-5, -h), 0, -1},
+		{pixel.V(-14, -9), -1, -1},
+		{pixel.V(-23, 17), -3, 2},
+		{pixel.V(0, 2*h-1), -1, 2},
+	}
+
+	for _, test := range tests {
+		q, r := g.hexCell(test.pos)
+		if q != test.q || r != test.r {
+			t.Errorf(
+				"%v in cell %d,%d, expected %d,%d",
+				test.pos,
+				q,
+				r,
+				test.q,
+				test.r,
+			)
+		}
+
+		center := g.hexCenter(test.q, test.r)
+		if snapped := g.Snap(test.pos); !near(snapped, center) {
+			t.Errorf("%v snapped to %v, expected %v", test.pos, snapped, center)
+		}
+	}
+}
+
+func TestHexCellOfCenter(t *testing.T) {
+	g := NewGrid(&GridConfig{Enabled: true, Shape: GridShapeHex, CellSize: 7})
+
+	for q := -6; q <= 6; q++ {
+		for r := -6; r <= 6; r++ {
+			if cq, cr := g.hexCell(g.hexCenter(q, r)); cq != q || cr != r {
+				t.Errorf("center of %d,%d is in cell %d,%d", q, r, cq, cr)
+			}
+		}
+	}
+}
+
+// TestSnapHexClosest checks that positions snap to the closest hex center.
+func TestSnapHexClosest(t *testing.T) {
+	g := NewGrid(&GridConfig{Enabled: true, Shape: GridShapeHex, CellSize: 10})
+
+	for x := -50.0; x <= 50; x += 1.3 {
+		for y := -50.0; y <= 50; y += 1.7 {
+			pos := pixel.V(x, y)
+			dist := g.Snap(pos).Sub(pos).Len()
+
+			for q := -10; q <= 10; q++ {
+				for r := -10; r <= 10; r++ {
+					if d := g.hexCenter(q, r).Sub(pos).Len(); d < dist-1e-9 {
+						t.Fatalf("%v is closer to cell %d,%d", pos, q, r)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSnapAngle(t *testing.T) {
+	origin := pixel.V(1, 1)
+	step := math.Pi / 2
+
+	tests := []struct {
+		angle    float64
+		step     float64
+		expected float64
+	}{
+		{0.1, step, 0},
+		{math.Pi/4 - 1e-6, step, 0},
+		{math.Pi/4 + 1e-6, step, math.Pi / 2},
+		{-math.Pi/4 + 1e-6, step, 0},
+		{-math.Pi/4 - 1e-6, step, -math.Pi / 2},
+		{3.1, step, math.Pi},
+		{-3.1, step, math.Pi},
+		{0.3, math.Pi / 6, math.Pi / 6},
+		{0.3, 0, 0.3},
+		{0.3, -1, 0.3},
+	}
+
+	for _, test := range tests {
+		pos := origin.Add(pixel.V(2, 0).Rotated(test.angle))
+		expected := origin.Add(pixel.V(2, 0).Rotated(test.expected))
+
+		snapped := snapAngle(origin, pos, test.step)
+		if !near(snapped, expected) {
+			t.Errorf(
+				"angle %g by step %g snapped to %v, expected %v",
+				test.angle,
+				test.step,
+				snapped,
+				expected,
+			)
+		}
+	}
+
+	if snapped := snapAngle(origin, origin, step); snapped != origin {
+		t.Errorf("origin snapped to %v", snapped)
+	}
+}
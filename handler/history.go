@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+
+	"private/grow/blob"
+)
+
+const defaultHistoryLimit = 100
+
+// Command is a reversible editor operation on the blob. Do is called again
+// on redo, so it has to record whatever Undo needs each time it runs.
+type Command interface {
+	Do(b *blob.Blob) error
+	Undo(b *blob.Blob) error
+}
+
+// History keeps the commands done by the editor so they can be undone and
+// redone. The blob's identifiers are restored with every undo and redo, a
+// redone command gives out the same IDs as the first time.
+type History struct {
+	blob   *blob.Blob
+	done   []*historyEntry
+	undone []*historyEntry
+	limit  int
+}
+
+type historyEntry struct {
+	command     Command
+	identifiers blob.Identifiers // before the command was done
+}
+
+func NewHistory(b *blob.Blob, limit int) *History {
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+
+	return &History{
+		blob:  b,
+		limit: limit,
+	}
+}
+
+// Do runs the command and records it, commands that fail are not recorded.
+func (h *History) Do(command Command) error {
+	identifiers := h.blob.Identifiers()
+
+	err := command.Do(h.blob)
+	if err != nil {
+		return err
+	}
+
+	h.push(&historyEntry{command: command, identifiers: identifiers})
+
+	return nil
+}
+
+// Record adds a command that has already been applied to the blob, such as a
+// node dragged across several frames. identifiers are the blob's from before
+// the command started.
+func (h *History) Record(command Command, identifiers blob.Identifiers) {
+	h.push(&historyEntry{command: command, identifiers: identifiers})
+}
+
+func (h *History) push(entry *historyEntry) {
+	h.done = append(h.done, entry)
+	if len(h.done) > h.limit {
+		h.done = h.done[len(h.done)-h.limit:]
+	}
+
+	h.undone = nil
+}
+
+// Undo reverts the last done command. A command that fails to undo stays
+// done, so that undo can be tried again.
+func (h *History) Undo() error {
+	if len(h.done) == 0 {
+		return errors.New("nothing to undo")
+	}
+
+	entry := h.done[len(h.done)-1]
+
+	err := entry.command.Undo(h.blob)
+	if err != nil {
+		return err
+	}
+
+	h.blob.SetIdentifiers(entry.identifiers)
+
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, entry)
+
+	return nil
+}
+
+// Redo does the last undone command again. A command that fails to redo
+// stays undone.
+func (h *History) Redo() error {
+	if len(h.undone) == 0 {
+		return errors.New("nothing to redo")
+	}
+
+	entry := h.undone[len(h.undone)-1]
+
+	h.blob.SetIdentifiers(entry.identifiers)
+
+	err := entry.command.Do(h.blob)
+	if err != nil {
+		return err
+	}
+
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, entry)
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"private/grow/blob"
+
+	"github.com/faiface/pixel"
+)
+
+var historyConf = &blob.BlobConfig{
+	Nodes: map[blob.NodeType]*blob.NodeConfig{
+		"none": {Radius: 5},
+	},
+	Unit: &blob.UnitConfig{},
+}
+
+// addNodeTestCommand adds a node, its undo fails while failUndo is set.
+type addNodeTestCommand struct {
+	id       int
+	failUndo bool
+}
+
+func (c *addNodeTestCommand) Do(b *blob.Blob) error {
+	c.id = b.AddNode(pixel.V(0, 0), "none")
+	return nil
+}
+
+func (c *addNodeTestCommand) Undo(b *blob.Blob) error {
+	if c.failUndo {
+		return errors.New("undo failed")
+	}
+
+	return b.RemoveNode(c.id)
+}
+
+func TestHistoryRedoGivesSameIDs(t *testing.T) {
+	b := blob.NewBlob(&blob.BlobJSON{}, historyConf)
+	h := NewHistory(b, 0)
+	command := &addNodeTestCommand{}
+
+	err := h.Do(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := command.id
+
+	for i := 0; i < 2; i++ {
+		err = h.Undo()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := b.Identifiers().Nodes; got != id {
+			t.Fatalf("node identifier %d, expected %d", got, id)
+		}
+
+		err = h.Redo()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if command.id != id {
+			t.Fatalf("redo added node %d, expected %d", command.id, id)
+		}
+	}
+}
+
+func TestHistoryKeepsFailedUndo(t *testing.T) {
+	b := blob.NewBlob(&blob.BlobJSON{}, historyConf)
+	h := NewHistory(b, 0)
+	command := &addNodeTestCommand{failUndo: true}
+
+	err := h.Do(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Undo() == nil {
+		t.Fatal("expected undo to fail")
+	}
+
+	command.failUndo = false
+
+	err = h.Undo()
+	if err != nil {
+		t.Fatalf("expected the command to stay undoable: %v", err)
+	}
+
+	if _, ok := b.Nodes[command.id]; ok {
+		t.Fatal("expected the node to be removed")
+	}
+}
+
+func TestHistoryRecord(t *testing.T) {
+	b := blob.NewBlob(&blob.BlobJSON{}, historyConf)
+	h := NewHistory(b, 0)
+	before := b.Identifiers()
+
+	command := &addNodeTestCommand{}
+	command.Do(b) // applied outside of the history
+
+	h.Record(command, before)
+
+	err := h.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Identifiers() != before {
+		t.Fatalf("identifiers %+v, expected %+v", b.Identifiers(), before)
+	}
+}
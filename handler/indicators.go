@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"fmt"
+	"image/color"
+
+	"private/grow/blob"
+	"private/grow/render"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/text"
+)
+
+const (
+	ghostAlpha      = 0.4
+	statusBarHeight = 20
+)
+
+var (
+	blockedColor   = color.RGBA{200, 40, 40, 255}
+	highlightColor = color.RGBA{80, 220, 80, 255}
+)
+
+// renderIndicators shows what a click does in the current mode: a ghost of
+// the node to add, a line to the node to connect or the node a unit would
+// spawn on.
+func (e *Editor) renderIndicators() {
+	imd := imdraw.New(nil)
+
+	switch e.mode {
+	case EditorModeAddNode:
+		conf, ok := e.conf.Nodes[e.addNodeType]
+		if !ok {
+			break
+		}
+
+		pos := e.grid.Snap(e.view.MousePos())
+
+		e.rend.Primitives(pos, ghost(conf.Graphics)...)
+		e.rend.Render()
+
+		if !e.blob.HasRoom(
+			pos,
+			e.addNodeType,
+			e.editorConf.MinSpacing,
+			nil,
+		) {
+			imd.Color = blockedColor
+			imd.Push(pos)
+			imd.Circle(conf.Radius, 2)
+		}
+	case EditorModeConnectNodes:
+		target, ok := e.blob.Nodes[e.target]
+		if !e.targetSet || !ok {
+			e.highlightClosest(imd, e.view.MousePos())
+			break
+		}
+
+		connColor, thickness := e.connectionGraphics()
+
+		imd.Color = fade(connColor, ghostAlpha)
+		imd.Push(target.Pos(), e.connectPos())
+		imd.Line(thickness)
+
+		e.highlightClosest(imd, e.connectPos())
+	case EditorModeAddUnit:
+		e.highlightClosest(imd, e.view.MousePos())
+	}
+
+	imd.Draw(e.win)
+}
+
+func (e *Editor) highlightClosest(imd *imdraw.IMDraw, pos pixel.Vec) {
+	id, err := e.blob.GetClosestNode(pos)
+	if err != nil {
+		return
+	}
+
+	node := e.blob.Nodes[id]
+
+	imd.Color = highlightColor
+	imd.Push(node.Pos())
+	imd.Circle(node.Radius()+3, 2)
+}
+
+// connectionGraphics returns the color and thickness of the connection type
+// being built.
+func (e *Editor) connectionGraphics() (color.RGBA, float64) {
+	connType := e.connectionType
+	if connType == "" {
+		connType = e.conf.DefaultConnection
+	}
+
+	conf, ok := e.conf.Connections[connType]
+	if !ok {
+		return color.RGBA{255, 255, 255, 255}, 8
+	}
+
+	return conf.Color, conf.Thickness
+}
+
+// renderStatus draws a bar at the top of the window with the current mode
+// and what a click does in it. It is drawn in window coordinates.
+func (e *Editor) renderStatus() {
+	bounds := e.win.Bounds()
+
+	imd := imdraw.New(nil)
+	imd.Color = color.RGBA{30, 30, 30, 255}
+	imd.Push(
+		pixel.V(bounds.Min.X, bounds.Max.Y-statusBarHeight),
+		bounds.Max,
+	)
+	imd.Rectangle(0)
+	imd.Draw(e.win)
+
+	txt := text.New(
+		pixel.V(bounds.Min.X+10, bounds.Max.Y-statusBarHeight+6),
+		e.atlas,
+	)
+
+	txt.WriteString(e.status())
+	txt.Draw(e.win, pixel.IM)
+}
+
+func (e *Editor) status() string {
+	if e.naming {
+		return "blueprint name: " + e.name + "_  (enter to save, esc to cancel)"
+	}
+
+	var status string
+
+	switch e.mode {
+	case EditorModeNone:
+		status = "no mode - click a node or unit to inspect it"
+	case EditorModeAddNode:
+		status = fmt.Sprintf(
+			"add node: %s - click to place",
+			e.nodeTypeLabel(e.addNodeType),
+		)
+	case EditorModeConnectNodes:
+		connType := e.connectionType
+		if connType == "" {
+			connType = e.conf.DefaultConnection
+		}
+
+		status = fmt.Sprintf("connect nodes: %s - click the ", connType)
+		if e.targetSet {
+			status += "second node"
+		} else {
+			status += "first node"
+		}
+	case EditorModeAddUnit:
+		status = "add unit - click near a node"
+	case EditorModeRemoveNode:
+		status = "remove node - click a node"
+	case EditorModeDisconnect:
+		status = "remove connection - click a connection"
+	case EditorModeMoveNode:
+		status = "move node - drag a node"
+	case EditorModeSetNodeType:
+		status = fmt.Sprintf(
+			"change type to %s - click a node",
+			e.nodeTypeLabel(e.setNodeType),
+		)
+	case EditorModeSelect:
+		status = fmt.Sprintf(
+			"select - click or drag a box, %d selected",
+			len(e.selected),
+		)
+	case EditorModePlace:
+		name := ""
+		if e.blueprint != nil {
+			name = e.blueprint.Name
+		}
+
+		status = fmt.Sprintf(
+			"place blueprint %s - click to place, r to rotate",
+			name,
+		)
+	default:
+		status = string(e.mode)
+	}
+
+	if e.grid.Enabled() {
+		status += "  [grid]"
+	}
+
+	return status
+}
+
+func (e *Editor) nodeTypeLabel(nodeType blob.NodeType) string {
+	conf, ok := e.conf.Nodes[nodeType]
+	if !ok || conf.Menu == nil || conf.Menu.Label == "" {
+		return string(nodeType)
+	}
+
+	return conf.Menu.Label
+}
+
+// ghost returns translucent copies of the primitives.
+func ghost(prims []*render.Primitive) []*render.Primitive {
+	ghosts := make([]*render.Primitive, 0, len(prims))
+
+	for _, prim := range prims {
+		p := *prim
+		p.Color = fade(p.Color, ghostAlpha)
+
+		ghosts = append(ghosts, &p)
+	}
+
+	return ghosts
+}
+
+// fade scales the color's alpha, colors are alpha premultiplied so all
+// channels are scaled.
+func fade(c color.RGBA, alpha float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(float64(c.R) * alpha),
+		G: uint8(float64(c.G) * alpha),
+		B: uint8(float64(c.B) * alpha),
+		A: uint8(float64(c.A) * alpha),
+	}
+}
@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"private/grow/blob"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+)
+
+const (
+	inspectorWidth      = 340
+	inspectorLineHeight = 14
+	inspectorPadding    = 8
+	charWidth           = 7 // of basicfont.Face7x13
+
+	// columns of the priority controls on a priority line
+	lowerColumn = 18
+	raiseColumn = 22
+
+	unitPickDistance = 8
+)
+
+// inspectorLine is a line of text in the inspector. Priority lines have
+// controls to change the priority, lower serves the node sooner.
+type inspectorLine struct {
+	text  string
+	lower func()
+	raise func()
+}
+
+// inspectedUnit returns the unit shown in the inspector, the unit clicked
+// without a mode.
+func (e *Editor) inspectedUnit() (int, bool) {
+	if !e.inspectingUnit {
+		return 0, false
+	}
+
+	if _, ok := e.blob.Units[e.inspectedUnitID]; !ok {
+		return 0, false // died
+	}
+
+	return e.inspectedUnitID, true
+}
+
+// inspectedNode returns the node shown in the inspector, the node clicked
+// without a mode or the only selected node.
+func (e *Editor) inspectedNode() (int, bool) {
+	if len(e.selected) == 1 {
+		return e.selectedIDs()[0], true
+	}
+
+	if !e.inspecting {
+		return 0, false
+	}
+
+	if _, ok := e.blob.Nodes[e.inspected]; !ok {
+		return 0, false
+	}
+
+	return e.inspected, true
+}
+
+// updateInspect picks the unit or node to inspect when no mode is active.
+// Units are drawn over nodes, so they are picked first.
+func (e *Editor) updateInspect() {
+	if !e.win.JustPressed(pixelgl.MouseButtonLeft) {
+		return
+	}
+
+	unitID, err := e.blob.GetUnitAt(e.view.MousePos(), unitPickDistance)
+	e.inspectedUnitID = unitID
+	e.inspectingUnit = err == nil
+
+	if e.inspectingUnit {
+		e.inspecting = false
+		return
+	}
+
+	id, err := e.blob.GetNodeAt(e.view.MousePos())
+	e.inspected = id
+	e.inspecting = err == nil
+}
+
+// updateInspector handles clicks on the inspector panel, it returns whether
+// the click was on the panel.
+func (e *Editor) updateInspector() bool {
+	if !e.win.JustPressed(pixelgl.MouseButtonLeft) {
+		return false
+	}
+
+	lines := e.inspectorLines()
+	if len(lines) == 0 {
+		return false
+	}
+
+	mouse := e.win.MousePosition()
+	if !e.inspectorRect(len(lines)).Contains(mouse) {
+		return false
+	}
+
+	for i, line := range lines {
+		if line.lower == nil {
+			continue
+		}
+
+		if e.inspectorControl(i, lowerColumn).Contains(mouse) {
+			line.lower()
+		}
+
+		if e.inspectorControl(i, raiseColumn).Contains(mouse) {
+			line.raise()
+		}
+	}
+
+	return true
+}
+
+func (e *Editor) inspectorLines() []*inspectorLine {
+	if unitID, ok := e.inspectedUnit(); ok {
+		return e.unitInspectorLines(unitID)
+	}
+
+	id, ok := e.inspectedNode()
+	if !ok {
+		return nil
+	}
+
+	info, err := e.blob.InspectNode(id)
+	if err != nil {
+		return nil
+	}
+
+	var lines []*inspectorLine
+
+	add := func(format string, a ...interface{}) {
+		lines = append(lines, &inspectorLine{text: fmt.Sprintf(format, a...)})
+	}
+
+	total := 0
+	for _, count := range info.Resources {
+		total += count
+	}
+
+	add("node %d (%s)", info.ID, info.NodeType)
+	add("pos %.0f, %.0f", info.Pos.X, info.Pos.Y)
+	add("resources %d/%d", total, info.ResourceCapacity)
+
+	for _, res := range blob.SortedKeys(info.Resources) {
+		add("  %s %d", res, info.Resources[res])
+	}
+
+	for _, res := range blob.SortedKeys(info.Piles) {
+		add("  %s %d on the ground", res, info.Piles[res])
+	}
+
+	if info.Recipe == "" {
+		add("recipe none")
+	} else {
+		progress := 0.0
+		if info.RecipeDuration > 0 {
+			progress = info.ProductionProgress / info.RecipeDuration
+		}
+
+		add("recipe %s %.0f%%", info.Recipe, progress*100)
+	}
+
+	priorityLines := func(priorities map[blob.ResourceType]int, producer bool) {
+		for _, res := range blob.SortedKeys(priorities) {
+			res := res
+			priority := priorities[res]
+
+			set := func(p int) func() {
+				return func() {
+					e.history.Do(&setPriorityCommand{
+						id:       id,
+						res:      res,
+						producer: producer,
+						priority: p,
+					})
+				}
+			}
+
+			lines = append(lines, &inspectorLine{
+				text: fmt.Sprintf(
+					"  %-12s %d  [-] [+]",
+					truncate(string(res), 12),
+					priority,
+				),
+				lower: set(priority - 1),
+				raise: set(priority + 1),
+			})
+		}
+	}
+
+	if len(info.Consumes) > 0 {
+		add("consumer priority (0 first)")
+		priorityLines(info.Consumes, false)
+	}
+
+	if len(info.Produces) > 0 {
+		add("producer priority (0 first)")
+		priorityLines(info.Produces, true)
+	}
+
+	if len(info.Jobs) > 0 {
+		add("jobs")
+	}
+
+	for _, job := range info.Jobs {
+		if job.HasUnit {
+			add("  #%d %s %s by unit %d", job.ID, job.JobType, job.State,
+				job.UnitID)
+		} else {
+			add("  #%d %s %s", job.ID, job.JobType, job.State)
+		}
+	}
+
+	if len(info.Units) == 0 {
+		add("no units heading here")
+	} else {
+		ids := make([]string, 0, len(info.Units))
+		for _, unitID := range info.Units {
+			ids = append(ids, fmt.Sprint(unitID))
+		}
+
+		add("units heading here: %s", strings.Join(ids, ", "))
+	}
+
+	return lines
+}
+
+func (e *Editor) unitInspectorLines(id int) []*inspectorLine {
+	info, err := e.blob.InspectUnit(id)
+	if err != nil {
+		return nil
+	}
+
+	var lines []*inspectorLine
+
+	add := func(format string, a ...interface{}) {
+		lines = append(lines, &inspectorLine{text: fmt.Sprintf(format, a...)})
+	}
+
+	add("unit %d on node %d", info.ID, info.NodeID)
+	add("hunger %.0f/%.0f", info.Hunger, info.MaxHunger)
+
+	if info.Resource == blob.ResourceTypeNone {
+		add("carrying nothing")
+	} else {
+		add("carrying %s", info.Resource)
+	}
+
+	if info.Retries > 0 {
+		add("looked for a consumer %d times", info.Retries)
+	}
+
+	if info.Job == nil {
+		add("no job")
+	} else {
+		progress := 0.0
+		if info.JobDuration > 0 {
+			progress = info.JobProgress / info.JobDuration
+		}
+
+		add("job #%d %s on node %d %.0f%%", info.Job.ID, info.Job.JobType,
+			info.Job.NodeID, progress*100)
+	}
+
+	add("procedure")
+
+	for _, step := range info.Procedure {
+		switch {
+		case step.ResourceType != blob.ResourceTypeNone:
+			add("  %s %s", step.StepType, step.ResourceType)
+		case step.StepType == blob.TraverseTo:
+			add("  %s %d", step.StepType, step.NodeID)
+		case step.StepType == blob.PickUpPile:
+			add("  %s #%d", step.StepType, step.PileID)
+		case step.StepType == blob.AwaitConsumer:
+			add("  %s %d ticks", step.StepType, step.Ticks)
+		default:
+			add("  %s", step.StepType)
+		}
+	}
+
+	add("recent steps")
+
+	for _, t := range info.Trace {
+		add("  %6d %s > %s @%d", t.Tick, t.From, t.To, t.NodeID)
+	}
+
+	return lines
+}
+
+// inspectorRect returns the panel in window coordinates, in the top right
+// corner below the status bar.
+func (e *Editor) inspectorRect(lines int) pixel.Rect {
+	bounds := e.win.Bounds()
+	top := bounds.Max.Y - statusBarHeight
+
+	return pixel.R(
+		bounds.Max.X-inspectorWidth,
+		top-float64(lines*inspectorLineHeight)-2*inspectorPadding,
+		bounds.Max.X,
+		top,
+	)
+}
+
+// inspectorLinePos returns the text origin of a line of the panel.
+func (e *Editor) inspectorLinePos(line int) pixel.Vec {
+	bounds := e.win.Bounds()
+
+	return pixel.V(
+		bounds.Max.X-inspectorWidth+inspectorPadding,
+		bounds.Max.Y-statusBarHeight-inspectorPadding-
+			float64((line+1)*inspectorLineHeight)+3,
+	)
+}
+
+// inspectorControl returns the rect of a three character control at the
+// column of a line.
+func (e *Editor) inspectorControl(line, column int) pixel.Rect {
+	pos := e.inspectorLinePos(line).Add(pixel.V(float64(column*charWidth), 0))
+
+	return pixel.R(pos.X, pos.Y-3, pos.X+3*charWidth, pos.Y+11)
+}
+
+// renderInspected marks the inspected node in the world, or the inspected
+// unit and the rest of its path.
+func (e *Editor) renderInspected() {
+	imd := imdraw.New(nil)
+	imd.Color = highlightColor
+
+	if unitID, ok := e.inspectedUnit(); ok {
+		info, err := e.blob.InspectUnit(unitID)
+		if err != nil {
+			return
+		}
+
+		if len(info.Path) > 1 {
+			imd.Push(info.Path...)
+			imd.Line(2)
+		}
+
+		imd.Push(e.blob.Units[unitID].Pos())
+		imd.Circle(unitPickDistance+2, 1)
+		imd.Draw(e.win)
+
+		return
+	}
+
+	id, ok := e.inspectedNode()
+	if !ok {
+		return
+	}
+
+	node := e.blob.Nodes[id]
+
+	imd.Push(node.Pos())
+	imd.Circle(node.Radius()+8, 1)
+	imd.Draw(e.win)
+}
+
+// renderInspector draws the panel in window coordinates.
+func (e *Editor) renderInspector() {
+	lines := e.inspectorLines()
+	if len(lines) == 0 {
+		return
+	}
+
+	imd := imdraw.New(nil)
+	imd.Color = color.RGBA{30, 30, 30, 230}
+
+	rect := e.inspectorRect(len(lines))
+	imd.Push(rect.Min, rect.Max)
+	imd.Rectangle(0)
+	imd.Draw(e.win)
+
+	for i, line := range lines {
+		txt := text.New(e.inspectorLinePos(i), e.atlas)
+		txt.WriteString(line.text)
+		txt.Draw(e.win, pixel.IM)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"image/color"
+
+	"private/grow/blob"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// updateSelect handles the select mode. Clicking a node selects it, dragging
+// from empty space selects the nodes in the box and dragging a selected node
+// moves the whole selection. Shift adds to the selection.
+func (e *Editor) updateSelect() {
+	if e.boxing {
+		if e.win.Pressed(pixelgl.MouseButtonLeft) {
+			return
+		}
+
+		e.boxing = false
+
+		if !e.shiftPressed() {
+			e.selected = make(map[int]bool)
+		}
+
+		rect := pixel.R(
+			e.boxStart.X,
+			e.boxStart.Y,
+			e.view.MousePos().X,
+			e.view.MousePos().Y,
+		).Norm()
+
+		for _, id := range e.blob.NodesIn(rect) {
+			e.selected[id] = true
+		}
+
+		return
+	}
+
+	if !e.win.JustPressed(pixelgl.MouseButtonLeft) {
+		return
+	}
+
+	id, err := e.blob.GetNodeAt(e.view.MousePos())
+	if err != nil {
+		e.boxing = true
+		e.boxStart = e.view.MousePos()
+
+		return
+	}
+
+	if e.selected[id] && e.shiftPressed() {
+		delete(e.selected, id)
+		return
+	}
+
+	if !e.selected[id] {
+		if !e.shiftPressed() {
+			e.selected = make(map[int]bool)
+		}
+
+		e.selected[id] = true
+	}
+
+	e.startDrag(id, e.selectedIDs())
+}
+
+// updateSelectionKeys handles the keys acting on the selection: delete
+// removes it, ctrl+c copies it, ctrl+v pastes the copy at the cursor,
+// ctrl+d duplicates the selection at the cursor and ctrl+s saves it as a
+// blueprint.
+func (e *Editor) updateSelectionKeys() {
+	// nodes may be gone by undo or removal
+	for id := range e.selected {
+		if _, ok := e.blob.Nodes[id]; !ok {
+			delete(e.selected, id)
+		}
+	}
+
+	if e.dragging || e.boxing {
+		return
+	}
+
+	if len(e.selected) > 0 && (e.win.JustPressed(pixelgl.KeyDelete) ||
+		e.win.JustPressed(pixelgl.KeyBackspace)) {
+		e.history.Do(&removeNodesCommand{ids: e.selectedIDs()})
+		e.selected = make(map[int]bool)
+
+		return
+	}
+
+	if !e.ctrlPressed() {
+		return
+	}
+
+	switch {
+	case e.win.JustPressed(pixelgl.KeyC):
+		bp, err := e.blob.Blueprint(e.selectedIDs())
+		if err == nil {
+			e.clipboard = bp
+		}
+	case e.win.JustPressed(pixelgl.KeyV):
+		if e.clipboard != nil {
+			e.paste(e.clipboard)
+		}
+	case e.win.JustPressed(pixelgl.KeyD):
+		bp, err := e.blob.Blueprint(e.selectedIDs())
+		if err == nil {
+			e.paste(bp)
+		}
+	case e.win.JustPressed(pixelgl.KeyS):
+		e.startNaming()
+	}
+}
+
+// paste places the blueprint at the cursor and selects the new nodes.
+func (e *Editor) paste(bp *blob.Blueprint) {
+	pos := e.grid.Snap(e.view.MousePos())
+	if !e.blueprintFits(bp, pos) {
+		return
+	}
+
+	cmd := &placeBlueprintCommand{blueprint: bp, pos: pos}
+
+	err := e.history.Do(cmd)
+	if err != nil {
+		return
+	}
+
+	e.selected = make(map[int]bool)
+	for _, id := range cmd.ids {
+		e.selected[id] = true
+	}
+}
+
+func (e *Editor) selectedIDs() []int {
+	return blob.SortedKeys(e.selected)
+}
+
+func (e *Editor) renderSelection() {
+	imd := imdraw.New(nil)
+	imd.Color = color.RGBA{255, 220, 0, 255}
+
+	for _, id := range e.selectedIDs() {
+		node := e.blob.Nodes[id]
+
+		imd.Push(node.Pos())
+		imd.Circle(node.Radius()+5, 2)
+	}
+
+	if e.boxing {
+		imd.Push(e.boxStart, e.view.MousePos())
+		imd.Rectangle(1)
+	}
+
+	imd.Draw(e.win)
+}
@@ -29,7 +29,21 @@ type ViewJSON struct {
 	Transformation pixel.Matrix `json:"transformation"`
 }
 
+// DefaultViewJSON returns a view centred on the window at zoom 1, so that world
+// coordinates match window coordinates.
+func DefaultViewJSON(bounds pixel.Rect) *ViewJSON {
+	return &ViewJSON{
+		Pos:            bounds.Center(),
+		Zoom:           1,
+		Transformation: pixel.IM,
+	}
+}
+
 func NewView(vj *ViewJSON, conf *ViewConfig, win *pixelgl.Window) *View {
+	if vj == nil {
+		vj = DefaultViewJSON(win.Bounds())
+	}
+
 	v := &View{
 		win:            win,
 		pos:            vj.Pos,
@@ -38,6 +52,12 @@ func NewView(vj *ViewJSON, conf *ViewConfig, win *pixelgl.Window) *View {
 		conf:           conf,
 	}
 
+	if v.zoom <= 0 {
+		v.zoom = 1
+	}
+
+	v.zoom = math.Max(v.conf.MinZoom, math.Min(v.zoom, v.conf.MaxZoom))
+
 	v.update()
 
 	return v
@@ -65,21 +85,25 @@ func (v *View) getUpdates() bool {
 
 	var updated bool
 
-	if v.win.Pressed(pixelgl.KeyW) { // up
+	// ctrl with wasd are editor shortcuts, such as ctrl+s and ctrl+d
+	pan := !v.win.Pressed(pixelgl.KeyLeftControl) &&
+		!v.win.Pressed(pixelgl.KeyRightControl)
+
+	if pan && v.win.Pressed(pixelgl.KeyW) { // up
 		v.pos.Y += speed
 		updated = true
 	}
-	if v.win.Pressed(pixelgl.KeyS) { // down
+	if pan && v.win.Pressed(pixelgl.KeyS) { // down
 		v.pos.Y -= speed
 		updated = true
 
 	}
-	if v.win.Pressed(pixelgl.KeyA) { // left
+	if pan && v.win.Pressed(pixelgl.KeyA) { // left
 		v.pos.X -= speed
 		updated = true
 
 	}
-	if v.win.Pressed(pixelgl.KeyD) { // right
+	if pan && v.win.Pressed(pixelgl.KeyD) { // right
 		v.pos.X += speed
 		updated = true
 	}
@@ -125,6 +149,18 @@ func (v *View) UndoTransform() {
 	v.win.SetMatrix(pixel.IM)
 }
 
+// Bounds returns the part of the world visible in the window.
+func (v *View) Bounds() pixel.Rect {
+	bounds := v.win.Bounds()
+
+	return pixel.R(
+		v.transformation.Unproject(bounds.Min).X,
+		v.transformation.Unproject(bounds.Min).Y,
+		v.transformation.Unproject(bounds.Max).X,
+		v.transformation.Unproject(bounds.Max).Y,
+	)
+}
+
 func (v *View) MousePos() pixel.Vec {
 	return v.transformation.Unproject(v.win.MousePosition())
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"image/color"
+	"os"
 	"time"
 
 	"private/grow/blob"
@@ -21,11 +23,11 @@ type Handler struct {
 	frameDuration time.Duration
 }
 
-func NewHandler(fps int) (*Handler, error) {
+func NewHandler(fps int, bounds pixel.Rect) (*Handler, error) {
 	win, err := pixelgl.NewWindow(
 		pixelgl.WindowConfig{
 			Title:  "grow",
-			Bounds: pixel.R(0, 0, 800, 800),
+			Bounds: bounds,
 			VSync:  true,
 		},
 	)
@@ -53,50 +55,107 @@ func (h *Handler) FrameDelay() {
 	h.prevFrameTime = now
 }
 
-func run() {
-	h, err := NewHandler(60)
-	if err != nil {
-		panic(err)
-	}
+type playOptions struct {
+	conf     *config.Config
+	save     *config.Save
+	savePath string // where the save is recorded on exit
+	fps      int
+	window   pixel.Rect
+}
 
-	conf, err := config.LoadConfig("config.json")
+func play(opts *playOptions) error {
+	h, err := NewHandler(opts.fps, opts.window)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	save, err := config.LoadSave("save.json")
+	conf := opts.conf
+
+	b := blob.NewBlob(opts.save.Blob, &conf.Blob)
+
+	flushEvents, closeEvents, err := logEvents(b, conf.Events.Path)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	b := blob.NewBlob(save.Blob, &conf.Blob, h.win)
-	v := handler.NewView(save.View, &conf.View, h.win)
-	e := handler.NewEditor(h.win, v, b)
+	stats := blob.NewStats(b, conf.Stats.Interval, conf.Stats.Samples)
+
+	v := handler.NewView(opts.save.View, &conf.View, h.win)
+	e := handler.NewEditor(
+		h.win,
+		v,
+		b,
+		&conf.Editor,
+		&conf.Blob,
+		config.NewBlueprintLibrary(&conf.Blueprints),
+	)
+	o := handler.NewStatsOverlay(h.win, v, stats, &conf.Blob)
+	c := handler.NewClock(&conf.Clock, h.win)
+	a := config.NewAutosaver(&conf.Autosave)
 
 	for !h.win.Closed() {
+		for ticks := c.Ticks(); ticks > 0; ticks-- {
+			b.Update()
+			flushEvents()
+			stats.Update()
+
+			if a.Due(b.Tick()) {
+				err = a.Record(&config.Save{Blob: b.ToJSON(), View: v.ToJSON()})
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "autosave failed:", err)
+				}
+			}
+		}
+
 		h.win.Clear(color.RGBA{0, 0, 0, 255})
-		b.Render()
+		b.Render(h.rend, c.Alpha())
 		e.Render()
-
-		b.Update()
-		e.Update()
-		v.Update()
+		o.Render()
+
+		if e.Typing() {
+			e.Update() // keys go to the editor's prompt only
+		} else {
+			c.Update()
+			e.Update()
+			o.Update()
+			v.Update()
+		}
+		flushEvents() // of the editor's changes, also while paused
 		h.win.Update()
 		h.FrameDelay()
 	}
 
+	// the game is still saved if these fail
+	err = closeEvents()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if conf.Stats.Export != "" {
+		err = config.ExportStats(conf.Stats.Export, stats)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
 	err = config.RecordSave(
-		"save.json",
+		opts.savePath,
 		&config.Save{
 			Blob: b.ToJSON(),
 			View: v.ToJSON(),
 		},
 	)
 	if err != nil {
-		panic(err)
+		return errors.Wrap(err, "failed to record save")
 	}
+
+	return nil
 }
 
 func main() {
-	pixelgl.Run(run)
+	err := runCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "grow:", err)
+		os.Exit(1)
+	}
 }
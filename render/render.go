@@ -1,39 +1,38 @@
 package render
 
 import (
-	"fmt"
 	"image/color"
 
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/imdraw"
-	"github.com/faiface/pixel/pixelgl"
 	"github.com/faiface/pixel/text"
 	"golang.org/x/image/font/basicfont"
 )
 
 // Renderer is a convinient wrapper around imdraw.IMDraw to allow for easy
-// drawing of primitives such as lines and circles.
+// drawing of primitives such as lines and circles. It draws onto any
+// pixel.Target, so it does not depend on an OpenGL window.
 type Renderer struct {
-	win       *pixelgl.Window
+	target    pixel.Target
 	batch     *pixel.Batch
 	atlas     *text.Atlas
 	textBoxes []*TextBox
 }
 
-func NewRenderer(win *pixelgl.Window) *Renderer {
+func NewRenderer(target pixel.Target) *Renderer {
 	return &Renderer{
-		win:   win,
-		batch: pixel.NewBatch(&pixel.TrianglesData{}, nil),
-		atlas: text.NewAtlas(basicfont.Face7x13, text.ASCII),
+		target: target,
+		batch:  pixel.NewBatch(&pixel.TrianglesData{}, nil),
+		atlas:  text.NewAtlas(basicfont.Face7x13, text.ASCII),
 	}
 }
 
 func (r *Renderer) Render() {
 	for _, tb := range r.textBoxes {
-		tb.writer.Draw(r.win, pixel.IM.Scaled(tb.writer.Orig, tb.scale))
+		tb.writer.Draw(r.target, pixel.IM.Scaled(tb.writer.Orig, tb.scale))
 	}
 
-	r.batch.Draw(r.win)
+	r.batch.Draw(r.target)
 	r.batch.Clear()
 }
 
@@ -43,6 +42,11 @@ const (
 	PrimitiveCircle PrimitiveType = "circle"
 )
 
+// Valid returns whether the renderer can draw primitives of the type.
+func (t PrimitiveType) Valid() bool {
+	return t == PrimitiveCircle
+}
+
 type Primitive struct {
 	Type      PrimitiveType `json:"type"`
 	Offset    pixel.Vec     `json:"offset"`
@@ -62,7 +66,7 @@ func (r *Renderer) primitive(pos pixel.Vec, prim *Primitive) {
 	case PrimitiveCircle:
 		r.Circle(pos.Add(prim.Offset), prim.Color, prim.Radius, prim.Thickness)
 	default:
-		fmt.Println("Unknown primitive type:", prim.Type)
+		// not drawn, unknown types are reported when the config is validated
 	}
 }
 
@@ -104,7 +108,7 @@ func (r *Renderer) Text(
 	writer := text.New(pos, r.atlas)
 	writer.Color = color
 	writer.Write([]byte(data))
-	writer.Draw(r.win, pixel.IM.Scaled(writer.Orig, scale))
+	writer.Draw(r.target, pixel.IM.Scaled(writer.Orig, scale))
 }
 
 type TextBox struct {